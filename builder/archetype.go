@@ -0,0 +1,82 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"text/template"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+const defaultArchetype = "default"
+
+// The variables available to archetype templates
+type archetypeData struct {
+	Title  string
+	Slug   string
+	Author string
+	Date   YAMLDate
+	Type   string
+	Draft  bool
+	Tags   []string
+}
+
+var archetypeFuncs = template.FuncMap{
+	"formatTags": formatTags,
+}
+
+func getArchetypesDir(cfg config.Config) string {
+	if cfg.Directories.Archetypes == "" {
+		return filepath.Join(cfg.Root, "archetypes")
+	}
+	return cfg.Directories.Archetypes
+}
+
+// Returns the directory new content of the given kind is created in. Kinds
+// other than posts and pages get a directory of their own next to the posts.
+func getContentDir(cfg config.Config, kind string) string {
+	switch kind {
+	case contentTypePost.String():
+		return cfg.Directories.Posts
+	case contentTypePage.String():
+		return cfg.Directories.Pages
+	}
+	return filepath.Join(filepath.Dir(cfg.Directories.Posts), kind)
+}
+
+// Finds the archetype for a kind of content in the project or its theme,
+// falling back to the default archetype. Returns an empty name if there is
+// neither.
+func findArchetype(cfg config.Config, kind string) (fs.FS, string, error) {
+	fsys, err := layeredDir(cfg, themeArchetypes, getArchetypesDir(cfg))
+	if err != nil {
+		return nil, "", err
+	}
+	for _, name := range []string{kind, defaultArchetype} {
+		path := name + ".md"
+		_, err := fs.Stat(fsys, path)
+		if err == nil {
+			return fsys, path, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, "", fmt.Errorf("failed to read archetype %s: %w", path, err)
+		}
+	}
+	return nil, "", nil
+}
+
+func renderArchetype(fsys fs.FS, name string, data archetypeData) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(archetypeFuncs).ParseFS(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse archetype: %w", err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute archetype: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,204 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// A CSS or JavaScript file from the static directory, or the result of
+// combining and transforming such files. It is written to the output when
+// its Path is used.
+type Asset struct {
+	// The path of the asset in the static directory, e.g. css/main.css
+	Name string
+
+	content  []byte
+	pipeline *assetPipeline
+}
+
+// Writes the asset to the static output directory if it hasn't been, and
+// returns the URL path it is served from
+func (a *Asset) Path() (string, error) {
+	if err := a.pipeline.publish(a); err != nil {
+		return "", err
+	}
+	return a.pipeline.prefix + a.Name, nil
+}
+
+// Returns the Subresource Integrity hash of the asset, for the integrity
+// attribute of the tag that loads it
+func (a *Asset) Integrity() string {
+	sum := sha512.Sum384(a.content)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Returns the content of the asset, e.g. for inlining small stylesheets
+func (a *Asset) Content() string {
+	return string(a.content)
+}
+
+// Reads, combines, minifies and fingerprints assets for templates. Results
+// are kept for the length of a build, so that each is only worked out once
+// however many pages use it.
+type assetPipeline struct {
+	static fs.FS
+	prefix string
+	dist   string
+	// Results by the operation and inputs that made them
+	results map[string]*Asset
+	// The content written to each output path
+	published map[string][]byte
+}
+
+func newAssetPipeline(cfg config.Config) (*assetPipeline, error) {
+	static, err := layeredDir(cfg, themeStatic, cfg.Directories.Static)
+	if err != nil {
+		return nil, err
+	}
+	return &assetPipeline{
+		static:    static,
+		prefix:    staticURLPrefix(cfg),
+		dist:      getStaticOutputDir(cfg),
+		results:   make(map[string]*Asset),
+		published: make(map[string][]byte),
+	}, nil
+}
+
+// Returns the template functions for assets, used like
+// {{ $css := concat "css/main.css" (asset "css/a.css") (asset "css/b.css") | minify | fingerprint }}
+func (p *assetPipeline) funcs() template.FuncMap {
+	return template.FuncMap{
+		"asset":       p.asset,
+		"concat":      p.concat,
+		"minify":      p.minify,
+		"fingerprint": p.fingerprint,
+	}
+}
+
+// Returns the result stored under key, making it with fn if there isn't one
+func (p *assetPipeline) cached(key string, fn func() (*Asset, error)) (*Asset, error) {
+	if a, ok := p.results[key]; ok {
+		return a, nil
+	}
+	a, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	p.results[key] = a
+	return a, nil
+}
+
+func (p *assetPipeline) asset(name string) (*Asset, error) {
+	name = strings.TrimPrefix(name, "/")
+	return p.cached("asset "+name, func() (*Asset, error) {
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("asset %q is not a path in the static directory", name)
+		}
+		content, err := fs.ReadFile(p.static, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read asset: %w", err)
+		}
+		return &Asset{Name: name, content: content, pipeline: p}, nil
+	})
+}
+
+// Joins assets, given alone or in lists, into one asset called name
+func (p *assetPipeline) concat(name string, assets ...any) (*Asset, error) {
+	name = strings.TrimPrefix(name, "/")
+	var inputs []*Asset
+	for _, arg := range assets {
+		if a, ok := arg.(*Asset); ok {
+			inputs = append(inputs, a)
+			continue
+		}
+		list, err := toList(arg)
+		if err != nil {
+			return nil, fmt.Errorf("can't concat %T", arg)
+		}
+		for _, item := range toItems(list) {
+			a, ok := item.(*Asset)
+			if !ok {
+				return nil, fmt.Errorf("can't concat %T", item)
+			}
+			inputs = append(inputs, a)
+		}
+	}
+	// Results are identified by the operation and the assets it was given
+	key := "concat " + name
+	for _, a := range inputs {
+		key += fmt.Sprintf(" %p", a)
+	}
+	return p.cached(key, func() (*Asset, error) {
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("asset %q is not a path in the static directory", name)
+		}
+		var content bytes.Buffer
+		for _, a := range inputs {
+			content.Write(a.content)
+			// Files often don't end with a line break, and a JavaScript
+			// comment on the last line would hide the next file's code
+			if !bytes.HasSuffix(a.content, []byte("\n")) {
+				content.WriteByte('\n')
+			}
+		}
+		return &Asset{Name: name, content: content.Bytes(), pipeline: p}, nil
+	})
+}
+
+// Removes the comments and whitespace of a CSS or JavaScript asset
+func (p *assetPipeline) minify(a *Asset) (*Asset, error) {
+	return p.cached(fmt.Sprintf("minify %p", a), func() (*Asset, error) {
+		var content []byte
+		switch strings.ToLower(path.Ext(a.Name)) {
+		case ".css":
+			content = minifyCSS(a.content)
+		case ".js", ".mjs":
+			content = minifyJS(a.content)
+		default:
+			return nil, fmt.Errorf("can't minify %s, only CSS and JavaScript are supported", a.Name)
+		}
+		return &Asset{Name: a.Name, content: content, pipeline: p}, nil
+	})
+}
+
+// Adds a hash of an asset's content to its name, e.g. css/main.3f2a1c9e.css,
+// so that browsers can cache it for as long as they like
+func (p *assetPipeline) fingerprint(a *Asset) (*Asset, error) {
+	return p.cached(fmt.Sprintf("fingerprint %p", a), func() (*Asset, error) {
+		sum := sha256.Sum256(a.content)
+		ext := path.Ext(a.Name)
+		name := strings.TrimSuffix(a.Name, ext) + "." + hex.EncodeToString(sum[:])[:8] + ext
+		return &Asset{Name: name, content: a.content, pipeline: p}, nil
+	})
+}
+
+// Writes an asset to the static output directory
+func (p *assetPipeline) publish(a *Asset) error {
+	if published, ok := p.published[a.Name]; ok {
+		if !bytes.Equal(published, a.content) {
+			return fmt.Errorf("asset %s is made in more than one way, give each a different name", a.Name)
+		}
+		return nil
+	}
+	target := filepath.Join(p.dist, filepath.FromSlash(a.Name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(target, a.content, 0644); err != nil {
+		return fmt.Errorf("failed to write asset: %w", err)
+	}
+	p.published[a.Name] = a.content
+	return nil
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
-	"io"
 	"io/fs"
 	"log"
 	"os"
@@ -15,7 +14,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/adrg/frontmatter"
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
@@ -25,29 +23,6 @@ import (
 
 var EmbeddedFiles embed.FS
 
-type YAMLDate time.Time
-
-func (d YAMLDate) MarshalYAML() (interface{}, error) {
-	return time.Time(d).Format("2006-01-02"), nil
-}
-
-func (yd YAMLDate) Format(layout string) string {
-	return time.Time(yd).Format(layout)
-}
-
-func (d *YAMLDate) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var dateStr string
-	if err := unmarshal(&dateStr); err != nil {
-		return err
-	}
-	parsedDate, err := time.Parse("2006-01-02", dateStr)
-	if err != nil {
-		return err
-	}
-	*d = YAMLDate(parsedDate)
-	return nil
-}
-
 type postSlug string
 
 func newPostSlug(s string) postSlug {
@@ -58,34 +33,28 @@ func (s postSlug) String() string {
 	return string(s)
 }
 
-func getTemplateFilePath(config config.Config, cType contentType) string {
-	var templateName = cType.String()
-	return filepath.Join(config.Directories.Templates, templateName+config.Files.Extension)
+func getTemplateFileName(config config.Config, name string) string {
+	return filepath.ToSlash(name) + config.Files.Extension
 }
 
-func getOutputFilePath(config config.Config, name string, cType contentType) string {
+func getOutputFilePath(config config.Config, outputDir string, name string, cType contentType) string {
 	var baseDir string
 	switch cType {
 	case contentTypeBase:
 		log.Fatal("Base content has no output file path")
 	case contentTypeHome:
-		baseDir = config.Directories.Dist
+		return getIndexPath(config, outputDir)
 	case contentTypePage:
 		baseDir = config.Directories.Pages
 	case contentTypePost:
 		baseDir = config.Directories.Posts
 	}
-	outputDirPath := filepath.Join(config.Directories.Dist, filepath.Base(baseDir))
+	outputDirPath := filepath.Join(outputDir, filepath.Base(baseDir))
 	return filepath.Join(outputDirPath, name+config.Files.Extension)
 }
 
-func getPageOutputFilePath(config config.Config, title string) string {
-	outputDirPath := filepath.Join(config.Directories.Dist, filepath.Base(config.Directories.Pages))
-	return filepath.Join(outputDirPath, title+config.Files.Extension)
-}
-
-func getIndexPath(config config.Config) string {
-	return filepath.Join(config.Directories.Dist, "index"+config.Files.Extension)
+func getIndexPath(config config.Config, outputDir string) string {
+	return filepath.Join(outputDir, "index"+config.Files.Extension)
 }
 
 type contentType int32
@@ -120,6 +89,11 @@ func (c *contentType) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal(&s); err != nil {
 		return err
 	}
+	return c.UnmarshalText([]byte(s))
+}
+
+func (c *contentType) UnmarshalText(text []byte) error {
+	s := string(text)
 	for i, v := range contentTypeTemplates {
 		if v == s {
 			*c = contentType(i)
@@ -130,13 +104,27 @@ func (c *contentType) UnmarshalYAML(unmarshal func(interface{}) error) error {
 }
 
 type Matter struct {
-	Title   string      `yaml:"title"`
-	Author  string      `yaml:"author"`
-	Tags    []string    `yaml:"tags"`
-	Date    YAMLDate    `yaml:"date"`
-	Type    contentType `yaml:"type"`
-	Slug    postSlug    `yaml:"-"`
-	IsDraft bool        `yaml:"draft"`
+	Title      string      `yaml:"title" toml:"title" json:"title"`
+	Author     string      `yaml:"author" toml:"author" json:"author"`
+	Tags       []string    `yaml:"tags" toml:"tags" json:"tags"`
+	Date       YAMLDate    `yaml:"date" toml:"date" json:"date"`
+	Lastmod    YAMLDate    `yaml:"lastmod,omitempty" toml:"lastmod,omitempty" json:"lastmod,omitempty"`
+	Updated    YAMLDate    `yaml:"updated,omitempty" toml:"updated,omitempty" json:"updated,omitempty"`
+	ExpiryDate YAMLDate    `yaml:"expiry_date,omitempty" toml:"expiry_date,omitempty" json:"expiry_date,omitempty"`
+	Type       contentType `yaml:"type,omitempty" toml:"type,omitempty,omitzero" json:"type,omitempty"`
+	Slug       postSlug    `yaml:"-" toml:"-" json:"-"`
+	IsDraft    bool        `yaml:"draft" toml:"draft" json:"draft"`
+	// Links versions of the same content in different languages. Defaults
+	// to the file name without its language suffix.
+	TranslationKey string `yaml:"translation_key,omitempty" toml:"translation_key,omitempty" json:"translation_key,omitempty"`
+	// Names of the menus to list the content in, and its place in them
+	Menu       menuNames `yaml:"menu,omitempty" toml:"menu,omitempty" json:"menu,omitempty"`
+	Weight     int       `yaml:"weight,omitempty" toml:"weight,omitempty,omitzero" json:"weight,omitempty"`
+	MenuParent string    `yaml:"menu_parent,omitempty" toml:"menu_parent,omitempty" json:"menu_parent,omitempty"`
+	// The name of the template to render the content with, in place of
+	// the one for its type
+	Layout string         `yaml:"layout,omitempty" toml:"layout,omitempty" json:"layout,omitempty"`
+	Params map[string]any `yaml:"-" toml:"-" json:"-"`
 }
 
 type MarkdownData struct {
@@ -144,63 +132,303 @@ type MarkdownData struct {
 	Content     []byte
 	HTMLContent template.HTML
 	Path        string
+	WordCount   int
+	ReadingTime int
+	// The code of the language the content is written in
+	Language string
+	// Versions of this content in the site's other languages
+	Translations []Translation
+	// The older and newer posts either side of this one, if there are any
+	PrevInSection *MarkdownData `json:"-"`
+	NextInSection *MarkdownData `json:"-"`
+	// The files published alongside page bundles
+	Resources []Resource
+
+	sourcePath     string
+	outputPath     string
+	translationKey string
+	// The directory of a page bundle, empty for single files
+	bundleDir string
+	// The name of the section the content is in, e.g. posts
+	section string
 }
 
 type SiteData struct {
-	Title  string
-	Author string
-	Pages  []MarkdownData
+	Title    string
+	Author   string
+	BaseURL  string
+	Language string
+	// The display name of the language
+	LanguageName string
+	// The path of the home page in this language
+	HomePath string
+	// The path of the RSS feed of posts in this language
+	FeedPath string
+	// The environment being built for, e.g. production
+	Environment string
+	// All published posts in this language, newest first
+	Posts []MarkdownData
+	// All published pages in this language
+	Pages []MarkdownData
+	// Published content in sections other than posts and pages, newest
+	// first, e.g. .SiteData.Sections.talk
+	Sections map[string][]MarkdownData
+	// Content grouped by taxonomy, e.g. .SiteData.Taxonomies.tags
+	Taxonomies map[string][]Term
+	// Navigation menus keyed by name, e.g. .SiteData.Menus.main
+	Menus  map[string][]MenuEntry
+	Params map[string]any
+	// The contents of the files in the data directory
+	Data map[string]any
+}
+
+// The data passed to post and page templates
+type contentData struct {
+	MD       MarkdownData
+	SiteData *SiteData
+	// The URL path of the page being rendered
+	Path string
+	// Versions of the page in other languages
+	Translations []Translation
+}
+
+// The data passed to the home template
+type homeData struct {
+	MD       []MarkdownData
+	SiteData *SiteData
+	// The URL path of the page being rendered
+	Path string
+	// The content of _index.md in the content directory, if there is one
+	Home *MarkdownData
+	// Home pages in other languages
+	Translations []Translation
 }
 
-func Build(config config.Config) {
+func Build(config config.Config, opts BuildOptions) {
 	fmt.Println("Build starting...")
-	run(config)
+	err := run(config, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println("Build finished.")
 }
 
-func run(config config.Config) {
-	err := makeDirs(config)
+func run(config config.Config, opts BuildOptions) error {
+	err := setSiteLocation(config)
 	if err != nil {
-		log.Fatal("failed to make directories:", err)
+		return err
 	}
-	postMDData, err := getMarkdownData(config, contentTypePost, config.Directories.Posts)
+	err = checkTheme(config)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	postMarkdown := RenderAllMDToHTML(postMDData)
-	pageMDData, err := getMarkdownData(config, contentTypePage, config.Directories.Pages)
-	pageMarkdown := RenderAllMDToHTML(pageMDData)
-	siteData := SiteData{
-		Title:  config.Site.Title,
-		Author: config.Site.Author,
+	err = makeDirs(config)
+	if err != nil {
+		return fmt.Errorf("failed to make directories: %w", err)
 	}
-	err = renderPages(config, pageMarkdown, &siteData)
+	// Static files are copied first, so that assets made from them by
+	// templates replace them
+	err = copyStaticDir(config)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	err = renderPosts(config, postMarkdown, &siteData)
+	languages := getLanguages(config)
+	images, err := newImageProcessor(config)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	err = renderHome(config, postMarkdown, &siteData)
+	assets, err := newAssetPipeline(config)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	err = copyStaticDir(config)
+	postMarkdown, err := loadContent(config, contentTypePost, config.Directories.Posts, languages, images, opts)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	pageMarkdown, err := loadContent(config, contentTypePage, config.Directories.Pages, languages, images, opts)
+	if err != nil {
+		return err
+	}
+	linkTranslations(config, postMarkdown, languages)
+	linkTranslations(config, pageMarkdown, languages)
+	sectionDirs, err := customSections(config)
+	if err != nil {
+		return err
+	}
+	sectionMarkdown := make(map[string][]MarkdownData, len(sectionDirs))
+	for _, dir := range sectionDirs {
+		mds, err := loadContent(config, contentTypePost, dir, languages, images, opts)
+		if err != nil {
+			return err
+		}
+		linkTranslations(config, mds, languages)
+		sectionMarkdown[filepath.Base(dir)] = mds
+	}
+	translations, err := loadTranslations(config)
+	if err != nil {
+		return err
+	}
+	data, err := loadData(config)
+	if err != nil {
+		return err
+	}
+	homeContent, err := loadHomeContent(config, languages, images, opts)
+	if err != nil {
+		return err
 	}
 
+	for _, lang := range languages {
+		funcs := templateFuncs(config, lang, languages, translations, assets, opts.now())
+		posts := filterLanguage(postMarkdown, lang.Code)
+		pages := filterLanguage(pageMarkdown, lang.Code)
+		linkSection(posts)
+		sections := make(map[string][]MarkdownData, len(sectionDirs))
+		menuContent := [][]MarkdownData{pages, posts}
+		for _, dir := range sectionDirs {
+			mds := filterLanguage(sectionMarkdown[filepath.Base(dir)], lang.Code)
+			linkSection(mds)
+			sections[filepath.Base(dir)] = mds
+			menuContent = append(menuContent, mds)
+		}
+		siteData := SiteData{
+			Title:        lang.Title,
+			Author:       config.Site.Author,
+			BaseURL:      config.Site.BaseURL,
+			Language:     lang.Code,
+			LanguageName: lang.Name,
+			HomePath:     getHomePath(lang),
+			FeedPath:     getFeedPath(lang),
+			Environment:  config.Environment,
+			Posts:        posts,
+			Pages:        pages,
+			Sections:     sections,
+			Taxonomies:   buildTaxonomies(posts),
+			Menus:        buildMenus(config, lang, menuContent...),
+			Params:       config.Params,
+			Data:         data,
+		}
+		err = renderPages(config, pages, &siteData, funcs)
+		if err != nil {
+			return err
+		}
+		err = renderPosts(config, posts, &siteData, funcs)
+		if err != nil {
+			return err
+		}
+		for _, dir := range sectionDirs {
+			err = renderPosts(config, sections[filepath.Base(dir)], &siteData, funcs)
+			if err != nil {
+				return err
+			}
+		}
+		var home *MarkdownData
+		if md, ok := homeContent[lang.Code]; ok {
+			home = &md
+		}
+		err = renderHome(config, lang, posts, home, &siteData, homeTranslations(config, lang, languages), funcs)
+		if err != nil {
+			return err
+		}
+		err = renderFeed(config, lang, posts)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.Minify {
+		return minifyOutput(config)
+	}
+	return nil
+}
+
+// Reads, filters and renders all content of a type in every language, and
+// works out where it will be written
+func loadContent(config config.Config, cType contentType, sectionDir string, languages []Language, images *imageProcessor, opts BuildOptions) ([]MarkdownData, error) {
+	var mds []MarkdownData
+	for _, lang := range languages {
+		// The default language's content is in the content directory,
+		// along with content in other languages marked by file name
+		inputDir := filepath.Join(lang.ContentDir, filepath.Base(sectionDir))
+		if lang.IsDefault {
+			inputDir = sectionDir
+		} else if lang.ContentDir == "" {
+			continue
+		}
+		langMDs, err := getMarkdownData(config, cType, inputDir)
+		if errors.Is(err, fs.ErrNotExist) && !(sectionDir == config.Directories.Posts && lang.IsDefault) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range langMDs {
+			var name string
+			name, langMDs[i].Language = splitLanguageSuffix(filepath.Base(langMDs[i].sourcePath), languages, lang.Code)
+			if langMDs[i].bundleDir != "" {
+				name = filepath.Base(langMDs[i].bundleDir)
+			}
+			langMDs[i].section = filepath.Base(sectionDir)
+			langMDs[i].translationKey = langMDs[i].Frontmatter.TranslationKey
+			if langMDs[i].translationKey == "" {
+				langMDs[i].translationKey = filepath.Base(sectionDir) + "/" + name
+			}
+		}
+		mds = append(mds, langMDs...)
+	}
+	mds = filterPublished(mds, opts)
+	sortByDate(mds)
+	// Paths are needed before rendering, for the images in page bundles
+	err := assignPaths(config, mds, cType, languages)
+	if err != nil {
+		return nil, err
+	}
+	err = assignResources(mds)
+	if err != nil {
+		return nil, err
+	}
+	mds = RenderAllMDToHTML(mds, images)
+	addReadingStats(mds, config.Site.WordsPerMinute)
+	return mds, nil
+}
+
+// Sets the output file and URL path of each piece of content
+func assignPaths(config config.Config, mds []MarkdownData, cType contentType, languages []Language) error {
+	for i, md := range mds {
+		outputDir := getLanguageDir(config, findLanguage(languages, md.Language))
+		switch cType {
+		case contentTypePost:
+			mds[i].outputPath = getPostOutputFilePath(config, outputDir, md)
+		case contentTypePage:
+			if md.bundleDir != "" {
+				name := filepath.Join(filepath.Base(md.bundleDir), "index")
+				mds[i].outputPath = getOutputFilePath(config, outputDir, name, cType)
+				break
+			}
+			fallthrough
+		default:
+			mds[i].outputPath = getOutputFilePath(config, outputDir, md.Frontmatter.Title, cType)
+		}
+		var err error
+		mds[i].Path, err = getURLPath(config, mds[i].outputPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func makeDirs(config config.Config) error {
 	outputDirPath := config.Directories.Dist
 	outputPostsPath := filepath.Join(outputDirPath, filepath.Base(config.Directories.Posts))
+	outputPagesPath := filepath.Join(outputDirPath, filepath.Base(config.Directories.Pages))
 	outputStaticPath := filepath.Join(outputDirPath, "static")
 	err := os.MkdirAll(outputPostsPath, os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("failed to create posts directory")
 	}
+	err = os.MkdirAll(outputPagesPath, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create pages directory")
+	}
 	err = os.MkdirAll(outputStaticPath, os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("failed to create static directory")
@@ -221,38 +449,42 @@ func makeDirs(config config.Config) error {
 	return nil
 }
 
-func renderPages(config config.Config, mds []MarkdownData, siteData *SiteData) error {
-	err := executeTemplates(config, mds, siteData, contentTypePage)
+func renderPages(config config.Config, mds []MarkdownData, siteData *SiteData, funcs template.FuncMap) error {
+	err := executeTemplates(config, mds, siteData, contentTypePage, funcs)
 	return err
 }
 
-func renderPosts(config config.Config, mds []MarkdownData, siteData *SiteData) error {
-	if len(mds) < 1 {
-		return fmt.Errorf("Site data contained no Markdown data")
-	}
-	err := executeTemplates(config, mds, siteData, contentTypePost)
+func renderPosts(config config.Config, mds []MarkdownData, siteData *SiteData, funcs template.FuncMap) error {
+	err := executeTemplates(config, mds, siteData, contentTypePost, funcs)
 	return err
 }
 
-func renderHome(config config.Config, mds []MarkdownData, siteData *SiteData) error {
+func renderHome(config config.Config, lang Language, mds []MarkdownData, home *MarkdownData, siteData *SiteData, translations []Translation, funcs template.FuncMap) error {
 	// Home template will inherit from base
-	baseTmplFilePath := getTemplateFilePath(config, contentTypeBase)
-	homeTmplFilePath := getTemplateFilePath(config, contentTypeHome)
-	outputFilePath := getIndexPath(config)
-	tmpl, err := template.ParseFiles(baseTmplFilePath, homeTmplFilePath)
+	outputFilePath := getIndexPath(config, getLanguageDir(config, lang))
+	layout := contentTypeHome.String()
+	if home != nil {
+		layout = lookupLayout(config, *home, contentTypeHome)
+	}
+	tmpl, err := parseTemplates(config, funcs, contentTypeBase.String(), layout)
 	if err != nil {
 		return fmt.Errorf("failed to parse files: %w", err)
 	}
+	err = os.MkdirAll(filepath.Dir(outputFilePath), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
 	outputFile, err := os.Create(outputFilePath)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
-	data := struct {
-		MD       []MarkdownData
-		SiteData *SiteData
-	}{
-		mds,
-		siteData,
+	defer outputFile.Close()
+	data := homeData{
+		MD:           mds,
+		SiteData:     siteData,
+		Path:         getHomePath(lang),
+		Home:         home,
+		Translations: translations,
 	}
 	err = tmpl.Execute(outputFile, data)
 	if err != nil {
@@ -261,44 +493,33 @@ func renderHome(config config.Config, mds []MarkdownData, siteData *SiteData) er
 	return nil
 }
 
-func executeTemplates(config config.Config, mds []MarkdownData, siteData *SiteData, cType contentType) error {
-	sort.Slice(mds, func(i, j int) bool {
-		return time.Time(mds[i].Frontmatter.Date).After(time.Time(mds[j].Frontmatter.Date))
-	})
-	for i, md := range mds {
+func executeTemplates(config config.Config, mds []MarkdownData, siteData *SiteData, cType contentType, funcs template.FuncMap) error {
+	for _, md := range mds {
 		// Post template will inherit from base template
-		baseTmplFilePath := getTemplateFilePath(config, contentTypeBase)
-		contentTmplFilePath := getTemplateFilePath(config, cType)
-		tmpl, err := template.ParseFiles(baseTmplFilePath, contentTmplFilePath)
+		tmpl, err := parseTemplates(config, funcs, contentTypeBase.String(), lookupLayout(config, md, cType))
 		if err != nil {
 			return fmt.Errorf("failed to parse templates: %w", err)
 		}
-		var fileName string
-
-		switch cType {
-		case contentTypePost:
-			fileName = md.Frontmatter.Slug.String()
-		default:
-			fileName = md.Frontmatter.Title
+		err = os.MkdirAll(filepath.Dir(md.outputPath), os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
 		}
-
-		outputFilePath := getOutputFilePath(config, fileName, cType)
-		mds[i].Path, err = filepath.Rel(config.Directories.Dist, outputFilePath)
+		err = copyResources(md)
 		if err != nil {
-			return fmt.Errorf("failed to generate relative path for output file: %w", err)
+			return err
 		}
-		outputFile, err := os.Create(outputFilePath)
+		outputFile, err := os.Create(md.outputPath)
 		if err != nil {
 			return fmt.Errorf("failed to create output file: %w", err)
 		}
-		data := struct {
-			MD       MarkdownData
-			SiteData *SiteData
-		}{
-			md,
-			siteData,
+		data := contentData{
+			MD:           md,
+			SiteData:     siteData,
+			Path:         md.Path,
+			Translations: md.Translations,
 		}
 		err = tmpl.Execute(outputFile, data)
+		outputFile.Close()
 		if err != nil {
 			return fmt.Errorf("failed to execute template: %w", err)
 		}
@@ -306,73 +527,34 @@ func executeTemplates(config config.Config, mds []MarkdownData, siteData *SiteDa
 	return nil
 }
 
-// make copydir func for recursion in copyStaticDir
-func copyDir(src string, dst string) error {
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return fmt.Errorf("failed to get directory info for src dir: %w", err)
-	}
-	if err := os.MkdirAll(dst, srcInfo.Mode()); err != nil {
-		return fmt.Errorf("failed to make dst directory: %w", err)
-	}
-
-	entries, err := os.ReadDir(src)
-	if err != nil {
-		return fmt.Errorf("failed to read src dir: %w", err)
-	}
-
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
-
-		if entry.IsDir() {
-			err = copyDir(srcPath, dstPath)
-			if err != nil {
-				return fmt.Errorf("failed to copy dir: %w", err)
-			}
-		} else {
-			err = copyFile(srcPath, dstPath)
-			if err != nil {
-				return fmt.Errorf("failed to copy file: %w", err)
-			}
-
+// Sorts newest first. Content published at the same moment is ordered by
+// title so that builds are reproducible.
+func sortByDate(mds []MarkdownData) {
+	sort.SliceStable(mds, func(i, j int) bool {
+		a, b := mds[i].Frontmatter, mds[j].Frontmatter
+		if time.Time(a.Date).Equal(time.Time(b.Date)) {
+			return a.Title < b.Title
 		}
-	}
-
-	return nil
+		return a.Date.After(b.Date)
+	})
 }
 
-func copyFile(src string, dst string) error {
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return fmt.Errorf("failed to get file info for src file: %w", err)
-	}
-
-	if !srcInfo.Mode().IsRegular() {
-		return fmt.Errorf("%s is not a regular file", src)
+// The directory static files are copied to, e.g. dist/static
+func getStaticOutputDir(config config.Config) string {
+	if config.Directories.Static == "" {
+		return filepath.Join(config.Directories.Dist, "static")
 	}
-
-	source, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
-	}
-	defer source.Close()
-
-	destination, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("failed to create dst file: %w", err)
-	}
-	defer destination.Close()
-
-	_, err = io.Copy(destination, source)
-	return err
+	return filepath.Join(config.Directories.Dist, filepath.Base(config.Directories.Static))
 }
 
+// Copies the theme's static files and the project's, which replace the
+// theme's with the same path
 func copyStaticDir(config config.Config) error {
-	staticDir := config.Directories.Static
-	destDir := filepath.Join(config.Directories.Dist, filepath.Base(staticDir))
-	err := copyDir(staticDir, destDir)
-	return err
+	fsys, err := layeredDir(config, themeStatic, config.Directories.Static)
+	if err != nil {
+		return err
+	}
+	return copyFS(fsys, getStaticOutputDir(config))
 }
 
 // Iterates through the given directory and extracts Frontmatter and content from Markdown files
@@ -386,22 +568,37 @@ func getMarkdownData(config config.Config, cType contentType, inputDir string) (
 	var mu sync.Mutex
 	var contentFiles []MarkdownData
 
+	// Directories holding an index file are page bundles, with the files
+	// their content uses kept alongside it
+	var sources, bundleDirs []string
 	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".md") {
-			continue
+		sourcePath := filepath.Join(inputDir, file.Name())
+		if file.IsDir() {
+			indexes, err := bundleIndexes(sourcePath)
+			if err != nil {
+				return nil, err
+			}
+			for _, index := range indexes {
+				sources = append(sources, index)
+				bundleDirs = append(bundleDirs, sourcePath)
+			}
+		} else if strings.HasSuffix(file.Name(), ".md") {
+			sources = append(sources, sourcePath)
+			bundleDirs = append(bundleDirs, "")
 		}
+	}
+
+	for i := range sources {
 		wg.Add(1)
-		go func(file os.DirEntry) {
+		go func(sourcePath string, bundleDir string) {
 			defer wg.Done()
-			filepath := filepath.Join(inputDir, file.Name())
-			data, err := os.ReadFile(filepath)
+			data, err := os.ReadFile(sourcePath)
 			if err != nil {
 				log.Println("Error reading file:", err)
 				return
 			}
 
-			var matter Matter
-			content, err := frontmatter.Parse(strings.NewReader(string(data)), &matter)
+			matter, content, err := parseMatter(data)
 			if err != nil {
 				log.Println("Error parsing frontmatter from file:", err)
 				return
@@ -411,16 +608,21 @@ func getMarkdownData(config config.Config, cType contentType, inputDir string) (
 					matter.Author = config.Author.Name
 				}
 				matter.Slug = newPostSlug(matter.Title)
+				if bundleDir != "" {
+					matter.Slug = newPostSlug(filepath.Base(bundleDir))
+				}
 			}
 			fileData := MarkdownData{
 				Frontmatter: matter,
 				Content:     content,
+				sourcePath:  sourcePath,
+				bundleDir:   bundleDir,
 			}
 			mu.Lock()
 			contentFiles = append(contentFiles, fileData)
 			mu.Unlock()
 
-		}(file)
+		}(sources[i], bundleDirs[i])
 	}
 	wg.Wait()
 
@@ -428,18 +630,28 @@ func getMarkdownData(config config.Config, cType contentType, inputDir string) (
 
 }
 
-func RenderAllMDToHTML(mds []MarkdownData) []MarkdownData {
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.SuperSubscript
-
-	htmlFlags := html.CommonFlags | html.LazyLoadImages
-	opts := html.RendererOptions{Flags: htmlFlags}
-
+func RenderAllMDToHTML(mds []MarkdownData, images *imageProcessor) []MarkdownData {
 	for i := range mds {
-		parser := parser.NewWithExtensions(extensions)
-		doc := parser.Parse(mds[i].Content)
-		renderer := html.NewRenderer(opts)
-		mds[i].HTMLContent = template.HTML(markdown.Render(doc, renderer))
+		var hook html.RenderNodeFunc
+		if images != nil {
+			hook = images.renderHook(mds[i])
+		}
+		mds[i].HTMLContent = renderMarkdown(mds[i].Content, hook)
 	}
 
 	return mds
 }
+
+// Renders Markdown to HTML, with hook rendering nodes such as images if it
+// isn't nil
+func renderMarkdown(content []byte, hook html.RenderNodeFunc) template.HTML {
+	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.SuperSubscript
+
+	htmlFlags := html.CommonFlags | html.LazyLoadImages
+	opts := html.RendererOptions{Flags: htmlFlags, RenderNodeHook: hook}
+
+	parser := parser.NewWithExtensions(extensions)
+	doc := parser.Parse(content)
+	renderer := html.NewRenderer(opts)
+	return template.HTML(markdown.Render(doc, renderer))
+}
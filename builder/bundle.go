@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A file in a page bundle, published next to the page that uses it
+type Resource struct {
+	// The path of the file in the bundle, e.g. photos/beach.jpg
+	Name string
+	// The URL path the file is published at
+	Path string
+	// The MIME type of the file, e.g. image/jpeg, or empty if it isn't known
+	MediaType string
+}
+
+// Reports whether the resource is an image, for listing galleries
+func (r Resource) IsImage() bool {
+	return strings.HasPrefix(r.MediaType, "image/")
+}
+
+// Returns the index files of a page bundle, index.md and its translations
+// such as index.fr.md, or nothing if dir isn't a bundle
+func bundleIndexes(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	var indexes []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "index.") || !strings.HasSuffix(name, ".md") {
+			continue
+		}
+		// At most one suffix, the language code
+		if strings.Count(name, ".") <= 2 {
+			indexes = append(indexes, filepath.Join(dir, name))
+		}
+	}
+	return indexes, nil
+}
+
+// Lists the files in a page bundle other than its Markdown, with the URLs
+// they are published at under the page's path
+func bundleResources(md MarkdownData) ([]Resource, error) {
+	var resources []Resource
+	err := filepath.WalkDir(md.bundleDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Hidden files such as .DS_Store aren't published
+		if strings.HasPrefix(d.Name(), ".") && p != md.bundleDir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(md.bundleDir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		resources = append(resources, Resource{
+			Name:      name,
+			Path:      path.Join(md.Path, name),
+			MediaType: mime.TypeByExtension(path.Ext(name)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page bundle %s: %w", md.bundleDir, err)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Name < resources[j].Name
+	})
+	return resources, nil
+}
+
+// Lists the resources of each page bundle
+func assignResources(mds []MarkdownData) error {
+	for i, md := range mds {
+		if md.bundleDir == "" {
+			continue
+		}
+		var err error
+		mds[i].Resources, err = bundleResources(md)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copies a page bundle's resources into the directory its page is rendered
+// to, so that relative links in its content resolve
+func copyResources(md MarkdownData) error {
+	outputDir := filepath.Dir(md.outputPath)
+	for _, r := range md.Resources {
+		target := filepath.Join(outputDir, filepath.FromSlash(r.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := copyFSFile(os.DirFS(md.bundleDir), r.Name, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
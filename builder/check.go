@@ -0,0 +1,48 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+var FrontmatterFormats = []string{"yaml", "toml", "json"}
+
+// Validates the config, including the settings only the builder knows the
+// valid values of
+func CheckConfig(cfg config.Config) []config.Issue {
+	issues := cfg.Validate()
+	fail := func(err error) {
+		issues = append(issues, config.Issue{Severity: config.Error, Message: err.Error()})
+	}
+	if cfg.Site.Permalinks != "" {
+		if err := ValidatePermalinks(cfg.Site.Permalinks); err != nil {
+			fail(fmt.Errorf("site.permalinks: %w", err))
+		}
+	}
+	if cfg.Files.Frontmatter != "" {
+		if err := validateChoice("frontmatter format", strings.ToLower(cfg.Files.Frontmatter), FrontmatterFormats); err != nil {
+			fail(fmt.Errorf("files.frontmatter: %w", err))
+		}
+	}
+	if cfg.Images.Format != "" {
+		if err := validateChoice("image format", strings.ToLower(cfg.Images.Format), ImageFormats); err != nil {
+			fail(fmt.Errorf("images.format: %w", err))
+		}
+	}
+	if cfg.Site.Language != "" {
+		if err := ValidateLanguage(cfg.Site.Language); err != nil {
+			fail(fmt.Errorf("site.language: %w", err))
+		}
+	}
+	if err := checkTheme(cfg); err != nil {
+		fail(fmt.Errorf("site.theme: %w", err))
+	}
+	for code := range cfg.Languages {
+		if err := ValidateLanguage(code); err != nil {
+			fail(fmt.Errorf("languages.%s: %w", code, err))
+		}
+	}
+	return issues
+}
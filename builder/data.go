@@ -0,0 +1,119 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/goccy/go-yaml"
+	"github.com/jmcharter/lumaca/config"
+)
+
+var dataExtensions = []string{".toml", ".yaml", ".yml", ".json", ".csv"}
+
+func getDataDir(cfg config.Config) string {
+	if cfg.Directories.Data == "" {
+		return filepath.Join(cfg.Root, "data")
+	}
+	return cfg.Directories.Data
+}
+
+// Loads every data file under the data directory into a map nested by
+// directory and keyed by file name without its extension, so that
+// data/talks/2024.yaml is available to templates as .SiteData.Data.talks
+// and can be reached with (index .SiteData.Data.talks "2024")
+func loadData(cfg config.Config) (map[string]any, error) {
+	data := make(map[string]any)
+	dir := getDataDir(cfg)
+	if _, err := os.Stat(dir); err != nil {
+		return data, nil
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || !contains(dataExtensions, ext) {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read data file: %w", err)
+		}
+		value, err := decodeData(content, ext)
+		if err != nil {
+			return fmt.Errorf("failed to decode data file %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		keys := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))), "/")
+		parent := data
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := parent[key].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				parent[key] = child
+			}
+			parent = child
+		}
+		parent[keys[len(keys)-1]] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Decodes a TOML, YAML, JSON or CSV file. Maps are decoded with string keys
+// and CSV files become a list of maps keyed by the header row.
+func decodeData(content []byte, ext string) (any, error) {
+	var value any
+	var err error
+	switch ext {
+	case ".toml":
+		var table map[string]any
+		err = toml.Unmarshal(content, &table)
+		value = table
+	case ".yaml", ".yml", ".json":
+		// JSON is a subset of YAML
+		err = yaml.Unmarshal(content, &value)
+	case ".csv":
+		value, err = decodeCSV(content)
+	default:
+		err = fmt.Errorf("unsupported file type %s", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normaliseParam(value), nil
+}
+
+func decodeCSV(content []byte) ([]any, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return []any{}, nil
+	}
+	header := records[0]
+	rows := make([]any, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,129 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// YAMLDate is the date of a piece of content. Despite the name it can be
+// decoded from YAML, TOML and JSON frontmatter.
+type YAMLDate time.Time
+
+// Layouts accepted for dates in frontmatter, tried in order. Layouts without
+// a UTC offset are interpreted in the site's timezone.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// The timezone used for dates that don't specify one. Set from the config
+// before any content is parsed.
+var siteLocation = time.Local
+
+func setSiteLocation(cfg config.Config) error {
+	if cfg.Site.Timezone == "" {
+		siteLocation = time.Local
+		return nil
+	}
+	loc, err := time.LoadLocation(cfg.Site.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", cfg.Site.Timezone, err)
+	}
+	siteLocation = loc
+	return nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		t, err := time.ParseInLocation(layout, s, siteLocation)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected a date such as 2006-01-02 or 2006-01-02T15:04:05Z07:00", s)
+}
+
+func (d YAMLDate) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// Dates are written as native TOML datetimes, or an empty string if unset
+func (d YAMLDate) MarshalTOML() ([]byte, error) {
+	if d.IsZero() {
+		return []byte(`""`), nil
+	}
+	return []byte(d.String()), nil
+}
+
+func (d YAMLDate) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d YAMLDate) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return time.Time(d).Format(time.RFC3339)
+}
+
+func (yd YAMLDate) Format(layout string) string {
+	return time.Time(yd).Format(layout)
+}
+
+func (d YAMLDate) IsZero() bool {
+	return time.Time(d).IsZero()
+}
+
+func (d YAMLDate) After(other YAMLDate) bool {
+	return time.Time(d).After(time.Time(other))
+}
+
+func (d *YAMLDate) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var dateStr string
+	if err := unmarshal(&dateStr); err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(dateStr))
+}
+
+// TOML has native date types, which are decoded as time.Time. Local dates
+// and datetimes carry no offset, so they are moved into the site's timezone.
+func (d *YAMLDate) UnmarshalTOML(v interface{}) error {
+	switch v := v.(type) {
+	case time.Time:
+		switch v.Location().String() {
+		case "datetime-local", "date-local":
+			v = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), siteLocation)
+		}
+		*d = YAMLDate(v)
+		return nil
+	case string:
+		return d.UnmarshalText([]byte(v))
+	}
+	return fmt.Errorf("invalid date: %v", v)
+}
+
+func (d *YAMLDate) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = YAMLDate{}
+		return nil
+	}
+	parsedDate, err := parseDate(string(text))
+	if err != nil {
+		return err
+	}
+	*d = YAMLDate(parsedDate)
+	return nil
+}
@@ -0,0 +1,147 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultDateFormat = "2006-01-02"
+
+// Month and day names of a language, used in place of Go's English names
+type dateNames struct {
+	months      [12]string
+	shortMonths [12]string
+	days        [7]string
+	shortDays   [7]string
+}
+
+var localDateNames = map[string]dateNames{
+	"fr": {
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		days:        [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortDays:   [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+	},
+	"de": {
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		days:        [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortDays:   [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+	},
+	"es": {
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		days:        [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		shortDays:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	},
+	"it": {
+		months:      [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		days:        [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		shortDays:   [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	},
+	"pt": {
+		months:      [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		shortMonths: [12]string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		days:        [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		shortDays:   [7]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+	},
+	"nl": {
+		months:      [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		days:        [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		shortDays:   [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+	},
+}
+
+// Layout elements that are replaced with localised names, longest first so
+// that January isn't read as Jan followed by "uary"
+var nameElements = []string{"January", "Monday", "Jan", "Mon"}
+
+// Formats a time using a Go layout, with month and day names in the given
+// language. Languages without names here use Go's English names.
+func formatLocalDate(t time.Time, layout string, lang string) string {
+	base, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	names, ok := localDateNames[base]
+	if !ok {
+		return t.Format(layout)
+	}
+	var b strings.Builder
+	start := 0
+	for i := 0; i < len(layout); {
+		element := ""
+		for _, e := range nameElements {
+			if strings.HasPrefix(layout[i:], e) {
+				element = e
+				break
+			}
+		}
+		if element == "" {
+			i++
+			continue
+		}
+		b.WriteString(t.Format(layout[start:i]))
+		switch element {
+		case "January":
+			b.WriteString(names.months[t.Month()-1])
+		case "Jan":
+			b.WriteString(names.shortMonths[t.Month()-1])
+		case "Monday":
+			b.WriteString(names.days[t.Weekday()])
+		case "Mon":
+			b.WriteString(names.shortDays[t.Weekday()])
+		}
+		i += len(element)
+		start = i
+	}
+	b.WriteString(t.Format(layout[start:]))
+	return b.String()
+}
+
+// Converts the date types templates have to hand into a time.Time
+func toTime(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case YAMLDate:
+		return time.Time(v), nil
+	case *YAMLDate:
+		return time.Time(*v), nil
+	case time.Time:
+		return v, nil
+	case string:
+		return parseDate(v)
+	}
+	return time.Time{}, fmt.Errorf("can't use %T as a date", v)
+}
+
+// Describes the time between t and now, e.g. "3 days ago", using the
+// translation keys for each unit
+func relativeDate(t time.Time, now time.Time, translate func(string, ...any) (string, error)) (string, error) {
+	d := now.Sub(t)
+	prefix := "ago"
+	if d < 0 {
+		d = -d
+		prefix = "in"
+	}
+	days := int(d.Hours() / 24)
+	var key string
+	var count int
+	switch {
+	case d < time.Minute:
+		return translate("just_now")
+	case d < time.Hour:
+		key, count = "minutes", int(d.Minutes())
+	case d < 24*time.Hour:
+		key, count = "hours", int(d.Hours())
+	case days < 30:
+		key, count = "days", days
+	case days < 365:
+		key, count = "months", days/30
+	default:
+		key, count = "years", days/365
+	}
+	if prefix == "ago" {
+		return translate(key+"_ago", count)
+	}
+	return translate("in_"+key, count)
+}
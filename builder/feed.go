@@ -0,0 +1,89 @@
+package builder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// The file name of each language's RSS feed, e.g. /feed.xml and /fr/feed.xml
+const feedFile = "feed.xml"
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+func getFeedPath(lang Language) string {
+	return getHomePath(lang) + feedFile
+}
+
+// Writes the RSS feed of a language's posts, newest first, next to its home
+// page
+func renderFeed(config config.Config, lang Language, posts []MarkdownData) error {
+	if config.Site.BaseURL == "" && lang.IsDefault {
+		fmt.Println("site.base_url isn't set, so links in feeds are relative to the site")
+	}
+	channel := rssChannel{
+		Title:       lang.Title,
+		Link:        absURL(config, getHomePath(lang)),
+		Description: lang.Title,
+		Language:    lang.Code,
+	}
+	for _, md := range posts {
+		link := absURL(config, md.Path)
+		item := rssItem{
+			Title:       md.Frontmatter.Title,
+			Link:        link,
+			GUID:        link,
+			Categories:  md.Frontmatter.Tags,
+			Description: string(md.HTMLContent),
+		}
+		if !md.Frontmatter.Date.IsZero() {
+			item.PubDate = time.Time(md.Frontmatter.Date).Format(time.RFC1123Z)
+		}
+		channel.Items = append(channel.Items, item)
+	}
+	// Posts are newest first, so the first was the last to change
+	if len(posts) > 0 && !posts[0].Frontmatter.Date.IsZero() {
+		channel.LastBuildDate = time.Time(posts[0].Frontmatter.Date).Format(time.RFC1123Z)
+	}
+
+	out, err := xml.MarshalIndent(rssFeed{Version: "2.0", Channel: channel}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode feed: %w", err)
+	}
+	outputFilePath := filepath.Join(getLanguageDir(config, lang), feedFile)
+	err = os.MkdirAll(filepath.Dir(outputFilePath), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	err = os.WriteFile(outputFilePath, append([]byte(xml.Header), out...), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+	return nil
+}
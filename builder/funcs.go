@@ -0,0 +1,61 @@
+package builder
+
+import (
+	"html/template"
+	"path"
+	"time"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// Returns the functions available to templates when rendering a language
+func templateFuncs(config config.Config, lang Language, languages []Language, t catalog, assets *assetPipeline, now time.Time) template.FuncMap {
+	translate := t.translateFunc(lang.Code, languages[0].Code)
+	dateFormat := config.Site.DateFormat
+	if dateFormat == "" {
+		dateFormat = defaultDateFormat
+	}
+	formatDate := func(layout string, date any) (string, error) {
+		t, err := toTime(date)
+		if err != nil {
+			return "", err
+		}
+		return formatLocalDate(t, layout, lang.Code), nil
+	}
+	funcs := helperFuncs(now)
+	funcs["T"] = translate
+	funcs["i18n"] = translate
+	// Formats a date with a Go layout, using month and day names in the
+	// current language, e.g. {{ .Date | formatDate "2 January 2006" }}
+	funcs["formatDate"] = formatDate
+	// Formats a date in the site's date format
+	funcs["date"] = func(date any) (string, error) {
+		return formatDate(dateFormat, date)
+	}
+	// Describes a date relative to the time of the build, e.g. 3 days ago
+	funcs["relativeDate"] = func(date any) (string, error) {
+		t, err := toTime(date)
+		if err != nil {
+			return "", err
+		}
+		return relativeDate(t, now, translate)
+	}
+	for name, fn := range assets.funcs() {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// Parses the named templates from the theme and the project, the first of
+// which is the one executed
+func parseTemplates(config config.Config, funcs template.FuncMap, names ...string) (*template.Template, error) {
+	fsys, err := layeredDir(config, themeTemplates, config.Directories.Templates)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range names {
+		paths = append(paths, getTemplateFileName(config, name))
+	}
+	return template.New(path.Base(paths[0])).Funcs(funcs).ParseFS(fsys, paths...)
+}
@@ -0,0 +1,521 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gosimple/slug"
+)
+
+// General purpose functions available to every template, alongside the
+// language and date functions added by templateFuncs
+func helperFuncs(now time.Time) template.FuncMap {
+	return template.FuncMap{
+		"first":       first,
+		"after":       after,
+		"where":       where,
+		"sortBy":      sortBy,
+		"groupByYear": groupByYear,
+		"truncate":    truncate,
+		"markdownify": markdownify,
+		"plainify":    plainify,
+		"urlize":      urlize,
+		"safeHTML":    safeHTML,
+		"dict":        dict,
+		"slice":       makeSlice,
+		"jsonify":     jsonify,
+		"now": func() time.Time {
+			return now
+		},
+	}
+}
+
+// Groups items sharing a year, as returned by groupByYear
+type YearGroup struct {
+	Year  int
+	Items []any
+}
+
+func toList(list any) (reflect.Value, error) {
+	v := reflect.ValueOf(list)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		return v, nil
+	case reflect.Invalid:
+		// A missing value, e.g. an unset param, is treated as an empty list
+		return reflect.ValueOf([]any{}), nil
+	}
+	return v, fmt.Errorf("can't use %T as a list", list)
+}
+
+func toItems(v reflect.Value) []any {
+	items := make([]any, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items
+}
+
+// Returns the first n items of a list, e.g. {{ range first 5 .SiteData.Posts }}
+func first(n int, list any) (any, error) {
+	v, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("first: can't take %d items", n)
+	}
+	if n > v.Len() {
+		n = v.Len()
+	}
+	return v.Slice(0, n).Interface(), nil
+}
+
+// Returns the items of a list after the first n
+func after(n int, list any) (any, error) {
+	v, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("after: can't skip %d items", n)
+	}
+	if n > v.Len() {
+		n = v.Len()
+	}
+	return v.Slice(n, v.Len()).Interface(), nil
+}
+
+// Looks up a dotted path of struct fields, map keys and methods without
+// arguments in v, e.g. "Frontmatter.Date" or "Params.series"
+func lookup(v any, path string) (any, error) {
+	current := reflect.ValueOf(v)
+	if path == "" || path == "." {
+		return v, nil
+	}
+	for _, name := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		// Looking up anything in a missing value, such as a nil item or an
+		// unset key, gives nothing
+		if !current.IsValid() {
+			return nil, nil
+		}
+		for current.Kind() == reflect.Interface || current.Kind() == reflect.Pointer {
+			if current.IsNil() {
+				return nil, nil
+			}
+			current = current.Elem()
+		}
+		if method := current.MethodByName(name); method.IsValid() && method.Type().NumIn() == 0 && method.Type().NumOut() == 1 {
+			current = method.Call(nil)[0]
+			continue
+		}
+		switch current.Kind() {
+		case reflect.Struct:
+			field := current.FieldByName(name)
+			// Unexported fields can't be read by templates either
+			if !field.IsValid() || !field.CanInterface() {
+				return nil, fmt.Errorf("%s has no field %s", current.Type(), name)
+			}
+			current = field
+		case reflect.Map:
+			if current.Type().Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("can't look up %s in %s", name, current.Type())
+			}
+			current = current.MapIndex(reflect.ValueOf(name).Convert(current.Type().Key()))
+		default:
+			return nil, fmt.Errorf("can't look up %s in %s", name, current.Type())
+		}
+	}
+	if !current.IsValid() || !current.CanInterface() {
+		return nil, nil
+	}
+	return current.Interface(), nil
+}
+
+// Compares two values of the same kind, returning false if they can't be
+// ordered. Dates, numbers, strings and booleans can be compared.
+func compare(a, b any) (int, bool) {
+	if ta, err := toTime(a); err == nil {
+		if _, ok := a.(string); !ok {
+			tb, err := toTime(b)
+			if err != nil {
+				return 0, false
+			}
+			switch {
+			case ta.Before(tb):
+				return -1, true
+			case ta.After(tb):
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	case bool:
+		b, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a == b:
+			return 0, true
+		case b:
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func equal(a, b any) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Reports whether list contains v, or if list is a string whether it
+// contains the string v
+func in(list any, v any) bool {
+	if s, ok := list.(string); ok {
+		sub, ok := v.(string)
+		return ok && strings.Contains(s, sub)
+	}
+	l, err := toList(list)
+	if err != nil {
+		return false
+	}
+	for i := 0; i < l.Len(); i++ {
+		if equal(l.Index(i).Interface(), v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(value any, op string, match any) (bool, error) {
+	switch op {
+	case "=", "==", "eq":
+		return equal(value, match), nil
+	case "!=", "<>", "ne":
+		return !equal(value, match), nil
+	case "in":
+		return in(match, value), nil
+	case "not in":
+		return !in(match, value), nil
+	case "intersect":
+		l, err := toList(value)
+		if err != nil {
+			return false, nil
+		}
+		for i := 0; i < l.Len(); i++ {
+			if in(match, l.Index(i).Interface()) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "<", "<=", ">", ">=", "lt", "le", "gt", "ge":
+		c, ok := compare(value, match)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case "<", "lt":
+			return c < 0, nil
+		case "<=", "le":
+			return c <= 0, nil
+		case ">", "gt":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("where: unknown operator %q", op)
+}
+
+// Returns the items of a list whose value at key matches, e.g.
+// {{ where .SiteData.Posts "Frontmatter.Author" "Jo" }}. An operator can be
+// given before the value: ==, !=, <, <=, >, >=, in, "not in" or intersect,
+// e.g. {{ where .SiteData.Posts "Frontmatter.Tags" "intersect" (slice "go") }}
+func where(list any, key string, args ...any) (any, error) {
+	v, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	op := "=="
+	var match any
+	switch len(args) {
+	case 1:
+		match = args[0]
+	case 2:
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("where: operator must be a string, not %T", args[0])
+		}
+		op = s
+		match = args[1]
+	default:
+		return nil, fmt.Errorf("where: expected a value or an operator and a value, got %d arguments", len(args))
+	}
+	result := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		value, err := lookup(v.Index(i).Interface(), key)
+		if err != nil {
+			return nil, fmt.Errorf("where: %w", err)
+		}
+		ok, err := matches(value, op, match)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = reflect.Append(result, v.Index(i))
+		}
+	}
+	return result.Interface(), nil
+}
+
+// Returns a copy of a list sorted by the value at key, ascending unless
+// "desc" is given, e.g. {{ sortBy .SiteData.Pages "Frontmatter.Title" }}.
+// Items whose values can't be compared keep their order.
+func sortBy(list any, key string, order ...string) (any, error) {
+	v, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	desc := false
+	if len(order) > 0 {
+		switch strings.ToLower(order[0]) {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return nil, fmt.Errorf("sortBy: order must be asc or desc, not %q", order[0])
+		}
+	}
+	keys := make([]any, v.Len())
+	indexes := make([]int, v.Len())
+	for i := range keys {
+		keys[i], err = lookup(v.Index(i).Interface(), key)
+		if err != nil {
+			return nil, fmt.Errorf("sortBy: %w", err)
+		}
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		c, _ := compare(keys[indexes[i]], keys[indexes[j]])
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	result := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for _, i := range indexes {
+		result = reflect.Append(result, v.Index(i))
+	}
+	return result.Interface(), nil
+}
+
+// Groups a list by the year of each item's date, newest year first, for
+// archive pages. The date is read from Frontmatter.Date unless another key
+// is given.
+func groupByYear(list any, key ...string) ([]YearGroup, error) {
+	v, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	path := "Frontmatter.Date"
+	if len(key) > 0 {
+		path = key[0]
+	}
+	var groups []YearGroup
+	index := make(map[int]int)
+	for _, item := range toItems(v) {
+		value, err := lookup(item, path)
+		if err != nil {
+			return nil, fmt.Errorf("groupByYear: %w", err)
+		}
+		date, err := toTime(value)
+		if err != nil {
+			return nil, fmt.Errorf("groupByYear: %w", err)
+		}
+		year := date.Year()
+		i, ok := index[year]
+		if !ok {
+			i = len(groups)
+			index[year] = i
+			groups = append(groups, YearGroup{Year: year})
+		}
+		groups[i].Items = append(groups[i].Items, item)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Year > groups[j].Year
+	})
+	return groups, nil
+}
+
+// Shortens text to at most length characters, breaking at a word where
+// possible and ending with an ellipsis, e.g. {{ truncate 140 .Summary }}.
+// HTML is converted to plain text first.
+func truncate(length int, text any, ellipsis ...string) (string, error) {
+	s, err := plainify(text)
+	if err != nil {
+		return "", err
+	}
+	end := "…"
+	if len(ellipsis) > 0 {
+		end = ellipsis[0]
+	}
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s, nil
+	}
+	cut := length - len([]rune(end))
+	if cut < 0 {
+		cut = 0
+	}
+	// Back up to the end of the last whole word, unless that loses it all
+	i := cut
+	for i > 0 && !unicode.IsSpace(runes[i]) {
+		i--
+	}
+	if i > 0 {
+		cut = i
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + end, nil
+}
+
+func toString(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case template.HTML:
+		return string(v), nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	case nil:
+		return "", nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("can't use %T as a string", v)
+}
+
+// Renders Markdown to HTML, e.g. for a description in the frontmatter.
+// A single paragraph is unwrapped so the result can be used inline.
+func markdownify(text any) (template.HTML, error) {
+	s, err := toString(text)
+	if err != nil {
+		return "", err
+	}
+	out := strings.TrimSpace(string(renderMarkdown([]byte(s), nil)))
+	if strings.HasPrefix(out, "<p>") && strings.HasSuffix(out, "</p>") && strings.Count(out, "<p>") == 1 {
+		out = strings.TrimSuffix(strings.TrimPrefix(out, "<p>"), "</p>")
+	}
+	return template.HTML(out), nil
+}
+
+// Strips HTML tags, leaving the text with its whitespace collapsed
+func plainify(text any) (string, error) {
+	s, err := toString(text)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(plainText(s)), " "), nil
+}
+
+// Converts text to a URL safe slug, e.g. "Hello World" becomes hello-world
+func urlize(text any) (string, error) {
+	s, err := toString(text)
+	if err != nil {
+		return "", err
+	}
+	return slug.Make(s), nil
+}
+
+// Marks a string as safe HTML so it isn't escaped. Only use it for trusted
+// content.
+func safeHTML(text any) (template.HTML, error) {
+	s, err := toString(text)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(s), nil
+}
+
+// Builds a map from pairs of keys and values, for passing several values to
+// a template, e.g. {{ template "card" dict "post" . "featured" true }}
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict: expected pairs of keys and values, got %d arguments", len(pairs))
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: keys must be strings, not %T", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// Builds a list from its arguments, e.g. {{ slice "go" "web" }}. This
+// replaces Go's builtin slice function; use first and after to take part of
+// a list.
+func makeSlice(items ...any) []any {
+	return items
+}
+
+// Encodes a value as JSON, e.g. for a data attribute. Values output inside
+// a <script> are already encoded as JavaScript without it.
+func jsonify(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("jsonify: %w", err)
+	}
+	return string(data), nil
+}
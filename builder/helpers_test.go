@@ -0,0 +1,183 @@
+package builder
+
+import (
+	"html/template"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPost(title, author string, date string, tags ...string) MarkdownData {
+	d, err := parseDate(date)
+	if err != nil {
+		panic(err)
+	}
+	return MarkdownData{Frontmatter: Matter{
+		Title:  title,
+		Author: author,
+		Date:   YAMLDate(d),
+		Tags:   tags,
+	}}
+}
+
+func TestHelperFuncs(t *testing.T) {
+	posts := []MarkdownData{
+		testPost("Carrots", "Jo", "2024-02-10", "food", "go"),
+		testPost("Apples", "Sam", "2023-11-05", "food"),
+		testPost("Bananas", "Jo", "2023-06-01"),
+	}
+	data := map[string]any{
+		"posts":  posts,
+		"empty":  []MarkdownData{},
+		"nils":   []any{nil, posts[0], nil},
+		"params": map[string]any{"series": "fruit"},
+		"inf":    math.Inf(1),
+	}
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{name: "first", tmpl: `{{range first 2 .posts}}{{.Frontmatter.Title}},{{end}}`, want: "Carrots,Apples,"},
+		{name: "first more than the list", tmpl: `{{len (first 10 .posts)}}`, want: "3"},
+		{name: "first of an empty list", tmpl: `{{len (first 2 .empty)}}`, want: "0"},
+		{name: "first of a missing list", tmpl: `{{len (first 2 .missing)}}`, want: "0"},
+		{name: "first negative", tmpl: `{{first -1 .posts}}`, wantErr: true},
+		{name: "first of a string", tmpl: `{{first 1 "abc"}}`, wantErr: true},
+
+		{name: "after", tmpl: `{{range after 1 .posts}}{{.Frontmatter.Title}},{{end}}`, want: "Apples,Bananas,"},
+		{name: "after the end", tmpl: `{{len (after 5 .posts)}}`, want: "0"},
+		{name: "after in an empty list", tmpl: `{{len (after 1 .empty)}}`, want: "0"},
+		{name: "after negative", tmpl: `{{after -1 .posts}}`, wantErr: true},
+
+		{name: "where equal", tmpl: `{{range where .posts "Frontmatter.Author" "Jo"}}{{.Frontmatter.Title}},{{end}}`, want: "Carrots,Bananas,"},
+		{name: "where not equal", tmpl: `{{range where .posts "Frontmatter.Author" "!=" "Jo"}}{{.Frontmatter.Title}},{{end}}`, want: "Apples,"},
+		{name: "where intersect", tmpl: `{{range where .posts "Frontmatter.Tags" "intersect" (slice "go" "web")}}{{.Frontmatter.Title}},{{end}}`, want: "Carrots,"},
+		{name: "where in", tmpl: `{{range where .posts "Frontmatter.Author" "in" (slice "Sam" "Alex")}}{{.Frontmatter.Title}},{{end}}`, want: "Apples,"},
+		{name: "where date", tmpl: `{{range where .posts "Frontmatter.Date" ">=" "2023-11-01"}}{{.Frontmatter.Title}},{{end}}`, want: "Carrots,Apples,"},
+		{name: "where method", tmpl: `{{len (where .posts "Frontmatter.Date.IsZero" false)}}`, want: "3"},
+		{name: "where missing param", tmpl: `{{len (where .posts "Frontmatter.Params.series" "fruit")}}`, want: "0"},
+		{name: "where in a map", tmpl: `{{len (where (slice .params) "series" "fruit")}}`, want: "1"},
+		{name: "where with nil items", tmpl: `{{range where .nils "Frontmatter.Title" "Carrots"}}{{.Frontmatter.Title}},{{end}}`, want: "Carrots,"},
+		{name: "where missing in nil items", tmpl: `{{len (where .nils "Frontmatter.Title" nil)}}`, want: "2"},
+		{name: "where in an empty list", tmpl: `{{len (where .empty "Frontmatter.Author" "Jo")}}`, want: "0"},
+		{name: "where unknown field", tmpl: `{{where .posts "Frontmatter.Colour" "red"}}`, wantErr: true},
+		{name: "where unknown operator", tmpl: `{{where .posts "Frontmatter.Author" "~" "Jo"}}`, wantErr: true},
+		{name: "where without a value", tmpl: `{{where .posts "Frontmatter.Author"}}`, wantErr: true},
+		{name: "where operator not a string", tmpl: `{{where .posts "Frontmatter.Author" 1 "Jo"}}`, wantErr: true},
+
+		{name: "sortBy", tmpl: `{{range sortBy .posts "Frontmatter.Title"}}{{.Frontmatter.Title}},{{end}}`, want: "Apples,Bananas,Carrots,"},
+		{name: "sortBy descending", tmpl: `{{range sortBy .posts "Frontmatter.Date" "desc"}}{{.Frontmatter.Title}},{{end}}`, want: "Carrots,Apples,Bananas,"},
+		{name: "sortBy with nil items", tmpl: `{{len (sortBy .nils "Frontmatter.Title")}}`, want: "3"},
+		{name: "sortBy an empty list", tmpl: `{{len (sortBy .empty "Frontmatter.Title")}}`, want: "0"},
+		{name: "sortBy unknown order", tmpl: `{{sortBy .posts "Frontmatter.Title" "up"}}`, wantErr: true},
+
+		{name: "groupByYear", tmpl: `{{range groupByYear .posts}}{{.Year}}:{{len .Items}} {{end}}`, want: "2024:1 2023:2 "},
+		{name: "groupByYear an empty list", tmpl: `{{range groupByYear .empty}}{{.Year}}{{end}}`, want: ""},
+		{name: "groupByYear with nil items", tmpl: `{{groupByYear .nils}}`, wantErr: true},
+		{name: "groupByYear not a date", tmpl: `{{groupByYear .posts "Frontmatter.Title"}}`, wantErr: true},
+
+		{name: "truncate at a word", tmpl: `{{truncate 10 "Hello wonderful world"}}`, want: "Hello…"},
+		{name: "truncate short text", tmpl: `{{truncate 10 "Hello"}}`, want: "Hello"},
+		{name: "truncate with an ellipsis", tmpl: `{{truncate 8 "Hello wonderful" "..."}}`, want: "Hello..."},
+		{name: "truncate HTML", tmpl: `{{truncate 20 (safeHTML "<p>Hi <b>there</b></p>")}}`, want: "Hi there"},
+		{name: "truncate nil", tmpl: `{{truncate 5 .missing}}`, want: ""},
+		{name: "truncate a list", tmpl: `{{truncate 5 .posts}}`, wantErr: true},
+
+		{name: "markdownify", tmpl: `{{markdownify "*hi* there"}}`, want: "<em>hi</em> there"},
+		{name: "markdownify paragraphs", tmpl: `{{markdownify "a\n\nb"}}`, want: "<p>a</p>\n\n<p>b</p>"},
+		{name: "markdownify nil", tmpl: `{{markdownify .missing}}`, want: ""},
+		{name: "markdownify a list", tmpl: `{{markdownify .posts}}`, wantErr: true},
+
+		{name: "plainify", tmpl: `{{plainify "<p>a  <i>b</i></p>\n<p>c</p>"}}`, want: "a b c"},
+		{name: "plainify a number", tmpl: `{{plainify 42}}`, want: "42"},
+		{name: "plainify a map", tmpl: `{{plainify .params}}`, wantErr: true},
+
+		{name: "urlize", tmpl: `{{urlize "Hello World"}}`, want: "hello-world"},
+		{name: "urlize nil", tmpl: `{{urlize .missing}}`, want: ""},
+		{name: "urlize a list", tmpl: `{{urlize .posts}}`, wantErr: true},
+
+		{name: "safeHTML", tmpl: `{{safeHTML "<b>x</b>"}}`, want: "<b>x</b>"},
+		{name: "unsafe HTML is escaped", tmpl: `{{"<b>x</b>"}}`, want: "&lt;b&gt;x&lt;/b&gt;"},
+		{name: "safeHTML a list", tmpl: `{{safeHTML .posts}}`, wantErr: true},
+
+		{name: "dict", tmpl: `{{with dict "a" 1 "b" "x"}}{{.a}}{{.b}}{{end}}`, want: "1x"},
+		{name: "dict empty", tmpl: `{{len dict}}`, want: "0"},
+		{name: "dict odd arguments", tmpl: `{{dict "a" 1 "b"}}`, wantErr: true},
+		{name: "dict key not a string", tmpl: `{{dict 1 2}}`, wantErr: true},
+
+		{name: "slice", tmpl: `{{range slice "a" "b"}}{{.}}{{end}}`, want: "ab"},
+		{name: "slice empty", tmpl: `{{len slice}}`, want: "0"},
+
+		{name: "jsonify", tmpl: `{{jsonify (slice 1 2 true)}}`, want: "[1,2,true]"},
+		{name: "jsonify nil", tmpl: `{{jsonify .missing}}`, want: "null"},
+		{name: "jsonify infinity", tmpl: `{{jsonify .inf}}`, wantErr: true},
+
+		{name: "now", tmpl: `{{now.Year}}`, want: "2024"},
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(helperFuncs(now)).Parse(tt.tmpl)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			var out strings.Builder
+			err = tmpl.Execute(&out, data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", out.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to execute template: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	post := testPost("Apples", "Sam", "2023-11-05")
+	tests := []struct {
+		name    string
+		v       any
+		path    string
+		want    any
+		wantErr bool
+	}{
+		{name: "field", v: post, path: "Frontmatter.Title", want: "Apples"},
+		{name: "pointer", v: &post, path: "Frontmatter.Author", want: "Sam"},
+		{name: "whole value", v: "x", path: ".", want: "x"},
+		{name: "nil", v: nil, path: "Frontmatter.Title", want: nil},
+		{name: "nil pointer", v: (*MarkdownData)(nil), path: "Frontmatter.Title", want: nil},
+		{name: "missing key", v: map[string]any{}, path: "a.b", want: nil},
+		{name: "nil value in a map", v: map[string]any{"a": nil}, path: "a.b", want: nil},
+		{name: "nested map", v: map[string]any{"a": map[string]any{"b": 1}}, path: "a.b", want: 1},
+		{name: "unknown field", v: post, path: "Frontmatter.Colour", wantErr: true},
+		{name: "unexported field", v: post, path: "section", wantErr: true},
+		{name: "in a string", v: "x", path: "Length", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookup(tt.v, tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
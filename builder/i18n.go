@@ -0,0 +1,244 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// Plural forms a translation can have, following the CLDR categories
+const (
+	pluralZero  = "zero"
+	pluralOne   = "one"
+	pluralTwo   = "two"
+	pluralFew   = "few"
+	pluralMany  = "many"
+	pluralOther = "other"
+)
+
+// A translated string is either a single string, or one string per plural form
+type message map[string]string
+
+// Translated template strings, keyed by language code then string key
+type catalog map[string]map[string]message
+
+var i18nExtensions = []string{".toml", ".yaml", ".yml", ".json"}
+
+func getI18nDir(cfg config.Config) string {
+	if cfg.Directories.I18n == "" {
+		return filepath.Join(cfg.Root, "i18n")
+	}
+	return cfg.Directories.I18n
+}
+
+// Loads translation files from the project's i18n directory, falling back to
+// the translations built in to lumaca for any that are missing
+func loadTranslations(cfg config.Config) (catalog, error) {
+	t := make(catalog)
+	sub, err := fs.Sub(EmbeddedFiles, "i18n")
+	if err == nil {
+		if err := t.load(sub); err != nil {
+			return nil, err
+		}
+	}
+	theme, err := themeDir(cfg, themeI18n)
+	if err != nil {
+		return nil, err
+	}
+	if theme != nil {
+		if err := t.load(theme); err != nil {
+			return nil, err
+		}
+	}
+	dir := getI18nDir(cfg)
+	if _, err := os.Stat(dir); err == nil {
+		if err := t.load(os.DirFS(dir)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t catalog) load(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !contains(i18nExtensions, ext) {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read translations %s: %w", entry.Name(), err)
+		}
+		decoded, err := decodeData(data, ext)
+		if err != nil {
+			return fmt.Errorf("failed to decode translations %s: %w", entry.Name(), err)
+		}
+		raw, ok := decoded.(map[string]any)
+		if !ok {
+			return fmt.Errorf("translations %s must be a table of keys", entry.Name())
+		}
+		lang := strings.ToLower(strings.TrimSuffix(entry.Name(), ext))
+		if t[lang] == nil {
+			t[lang] = make(map[string]message)
+		}
+		for key, value := range raw {
+			switch value := value.(type) {
+			case string:
+				t[lang][key] = message{pluralOther: value}
+			case map[string]any:
+				tr := make(message)
+				for form, s := range value {
+					tr[strings.ToLower(form)] = fmt.Sprint(s)
+				}
+				t[lang][key] = tr
+			default:
+				return fmt.Errorf("translation %q in %s must be a string or a table of plural forms", key, entry.Name())
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the languages to look a translation up in, most specific first,
+// e.g. fr-ca, fr, then the default language
+func fallbackLanguages(code string, defaultCode string) []string {
+	var codes []string
+	for _, c := range []string{code, defaultCode, "en"} {
+		c = strings.ToLower(c)
+		if c == "" {
+			continue
+		}
+		codes = append(codes, c)
+		if base, _, ok := strings.Cut(c, "-"); ok {
+			codes = append(codes, base)
+		}
+	}
+	return codes
+}
+
+// Returns the T template function for a language. T takes a translation key
+// and optionally a count, used to pick the plural form and available to the
+// translation as {{ .Count }}, or a map of values for the translation to use.
+// Keys without a translation are returned unchanged.
+func (t catalog) translateFunc(code string, defaultCode string) func(key string, args ...any) (string, error) {
+	languages := fallbackLanguages(code, defaultCode)
+	return func(key string, args ...any) (string, error) {
+		var data any
+		count := -1
+		if len(args) > 0 {
+			data = args[0]
+			if n, ok := toInt(args[0]); ok {
+				count = n
+				data = map[string]any{"Count": n}
+			}
+		}
+		for _, lang := range languages {
+			tr, ok := t[lang][key]
+			if !ok {
+				continue
+			}
+			form := pluralOther
+			if count >= 0 {
+				form = pluralForm(lang, count)
+			}
+			message, ok := tr[form]
+			if count == 0 && tr[pluralZero] != "" {
+				message, ok = tr[pluralZero], true
+			}
+			if !ok {
+				message = tr[pluralOther]
+			}
+			return executeTranslation(message, data)
+		}
+		return key, nil
+	}
+}
+
+func executeTranslation(message string, data any) (string, error) {
+	if !strings.Contains(message, "{{") {
+		return message, nil
+	}
+	tmpl, err := template.New("").Parse(message)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse translation %q: %w", message, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute translation %q: %w", message, err)
+	}
+	return buf.String(), nil
+}
+
+// Picks the plural form for a count using the CLDR rules of common languages.
+// Languages without rules here use the English rule.
+func pluralForm(lang string, n int) string {
+	base, _, _ := strings.Cut(lang, "-")
+	switch base {
+	case "ja", "zh", "ko", "vi", "th", "id", "ms":
+		return pluralOther
+	case "fr", "pt":
+		if n == 0 || n == 1 {
+			return pluralOne
+		}
+		return pluralOther
+	case "ru", "uk", "be", "sr", "hr", "bs":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return pluralOne
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return pluralFew
+		}
+		return pluralMany
+	case "pl":
+		switch {
+		case n == 1:
+			return pluralOne
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return pluralFew
+		}
+		return pluralMany
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return pluralOne
+		case n >= 2 && n <= 4:
+			return pluralFew
+		}
+		return pluralOther
+	}
+	if n == 1 {
+		return pluralOne
+	}
+	return pluralOther
+}
+
+func toInt(v any) (int, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rv.Uint()), true
+	}
+	return 0, false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
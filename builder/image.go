@@ -0,0 +1,334 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/jmcharter/lumaca/config"
+	"golang.org/x/image/draw"
+)
+
+const (
+	defaultImageQuality = 80
+	defaultImageSizes   = "100vw"
+)
+
+// The formats images can be converted to. WebP isn't supported as there is
+// no WebP encoder in Go's standard library or golang.org/x/image.
+var ImageFormats = []string{"jpeg", "png"}
+
+// A resized copy of an image
+type imageVariant struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// Resizes the images in content into the widths given in the config, caching
+// the results between builds
+type imageProcessor struct {
+	widths   []int
+	quality  int
+	sizes    string
+	format   string
+	static   fs.FS
+	prefix   string
+	dist     string
+	cacheDir string
+	// Variants by output path, smallest first, or nil for images that
+	// aren't processed
+	done map[string][]imageVariant
+}
+
+func getCacheDir(cfg config.Config) string {
+	if cfg.Directories.Cache == "" {
+		return filepath.Join(cfg.Root, ".cache")
+	}
+	return cfg.Directories.Cache
+}
+
+// The URL path static files are served from, e.g. /static/
+func staticURLPrefix(cfg config.Config) string {
+	return "/" + filepath.Base(getStaticOutputDir(cfg)) + "/"
+}
+
+// Returns a processor for the images in content, or nil if image processing
+// isn't enabled
+func newImageProcessor(cfg config.Config) (*imageProcessor, error) {
+	if len(cfg.Images.Widths) == 0 {
+		return nil, nil
+	}
+	static, err := layeredDir(cfg, themeStatic, cfg.Directories.Static)
+	if err != nil {
+		return nil, err
+	}
+	p := &imageProcessor{
+		quality:  cfg.Images.Quality,
+		sizes:    cfg.Images.Sizes,
+		format:   strings.ToLower(cfg.Images.Format),
+		static:   static,
+		prefix:   staticURLPrefix(cfg),
+		dist:     getStaticOutputDir(cfg),
+		cacheDir: filepath.Join(getCacheDir(cfg), "images"),
+		done:     make(map[string][]imageVariant),
+	}
+	if p.quality <= 0 {
+		p.quality = defaultImageQuality
+	}
+	if p.sizes == "" {
+		p.sizes = defaultImageSizes
+	}
+	p.widths = append(p.widths, cfg.Images.Widths...)
+	sort.Ints(p.widths)
+	return p, nil
+}
+
+// Where an image is read from and its variants are written to
+type imageSource struct {
+	fsys fs.FS
+	name string
+	// The output directory and the URL path it is served from
+	dist   string
+	prefix string
+}
+
+// Finds the source of an image in md's content, either in the static
+// directory or, for page bundles, a path relative to the bundle
+func (p *imageProcessor) resolve(src string, md MarkdownData) (imageSource, bool) {
+	u, err := url.Parse(src)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return imageSource{}, false
+	}
+	if strings.HasPrefix(u.Path, p.prefix) {
+		return imageSource{fsys: p.static, name: strings.TrimPrefix(u.Path, p.prefix), dist: p.dist, prefix: p.prefix}, true
+	}
+	name := path.Clean(u.Path)
+	if md.bundleDir == "" || !fs.ValidPath(name) || name == "." {
+		return imageSource{}, false
+	}
+	return imageSource{
+		fsys:   os.DirFS(md.bundleDir),
+		name:   name,
+		dist:   filepath.Dir(md.outputPath),
+		prefix: md.Path,
+	}, true
+}
+
+// Returns the variants of the image at src, resizing it if they aren't
+// cached. Returns nil for images that can't be processed, such as those on
+// other sites.
+func (p *imageProcessor) process(src string, md MarkdownData) ([]imageVariant, error) {
+	source, ok := p.resolve(src, md)
+	if !ok {
+		return nil, nil
+	}
+	key := filepath.Join(source.dist, filepath.FromSlash(source.name))
+	if variants, ok := p.done[key]; ok {
+		return variants, nil
+	}
+	variants, err := p.resize(source)
+	if err != nil {
+		return nil, err
+	}
+	p.done[key] = variants
+	return variants, nil
+}
+
+func (p *imageProcessor) outputFormat(name string) string {
+	if p.format != "" {
+		return p.format
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	}
+	return ""
+}
+
+func (p *imageProcessor) resize(source imageSource) ([]imageVariant, error) {
+	name := source.name
+	format := p.outputFormat(name)
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+	default:
+		// GIFs may be animated, and other formats can't be decoded
+		return nil, nil
+	}
+	data, err := fs.ReadFile(source.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	orientation := jpegOrientation(data)
+	width, height := info.Width, info.Height
+	if swapsDimensions(orientation) {
+		width, height = height, width
+	}
+
+	// Images are never enlarged, and the largest variant is the full size
+	// image without its metadata
+	var widths []int
+	for _, w := range p.widths {
+		if w < width {
+			widths = append(widths, w)
+		}
+	}
+	widths = append(widths, width)
+
+	hash := sha256.New()
+	hash.Write(data)
+	fmt.Fprintf(hash, "%s %d", format, p.quality)
+	key := hex.EncodeToString(hash.Sum(nil))[:16]
+	ext := "." + format
+	if format == "jpeg" {
+		ext = ".jpg"
+	}
+	base := strings.TrimSuffix(name, path.Ext(name))
+
+	var img image.Image
+	var variants []imageVariant
+	for _, w := range widths {
+		h := (height*w + width/2) / width
+		if h < 1 {
+			h = 1
+		}
+		cached := filepath.Join(p.cacheDir, fmt.Sprintf("%s-%dw%s", key, w, ext))
+		if _, err := os.Stat(cached); err != nil {
+			if img == nil {
+				img, _, err = image.Decode(bytes.NewReader(data))
+				if err != nil {
+					return nil, err
+				}
+				img = applyOrientation(img, orientation)
+			}
+			if err := p.encode(cached, scaleImage(img, w, h), format); err != nil {
+				return nil, err
+			}
+		}
+		out := fmt.Sprintf("%s-%dw%s", base, w, ext)
+		target := filepath.Join(source.dist, filepath.FromSlash(out))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := copyFSFile(os.DirFS(p.cacheDir), filepath.Base(cached), target); err != nil {
+			return nil, err
+		}
+		variants = append(variants, imageVariant{URL: source.prefix + out, Width: w, Height: h})
+	}
+	return variants, nil
+}
+
+func scaleImage(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	if b.Dx() == width && b.Dy() == height {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// Encodes an image into the cache. Go's encoders don't write metadata, so
+// EXIF data such as the location a photo was taken is left behind.
+func (p *imageProcessor) encode(target string, img image.Image, format string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	// Written under another name first, so an interrupted build can't
+	// leave a broken image in the cache
+	f, err := os.CreateTemp(filepath.Dir(target), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	switch format {
+	case "png":
+		err = png.Encode(f, img)
+	default:
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: p.quality})
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	return os.Rename(f.Name(), target)
+}
+
+// Renders Markdown images as responsive images, with a srcset of the
+// variants and their dimensions. Images that can't be processed are left
+// to the default renderer.
+func (p *imageProcessor) renderHook(md MarkdownData) func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	handled := make(map[ast.Node]bool)
+	return func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		img, ok := node.(*ast.Image)
+		if !ok {
+			return ast.GoToNext, false
+		}
+		if !entering {
+			return ast.GoToNext, handled[node]
+		}
+		variants, err := p.process(string(img.Destination), md)
+		if err != nil {
+			fmt.Printf("Skipping image %q in %q: %v\n", img.Destination, md.Frontmatter.Title, err)
+		}
+		if len(variants) == 0 {
+			return ast.GoToNext, false
+		}
+		handled[node] = true
+		io.WriteString(w, imageTag(img, variants, p.sizes))
+		return ast.SkipChildren, true
+	}
+}
+
+// Percent-encodes the path of a variant. Spaces and commas separate the
+// candidates in a srcset, so can't be left in their URLs.
+func imageURL(p string) string {
+	return strings.ReplaceAll((&url.URL{Path: p}).EscapedPath(), ",", "%2C")
+}
+
+func imageTag(img *ast.Image, variants []imageVariant, sizes string) string {
+	largest := variants[len(variants)-1]
+	srcset := make([]string, len(variants))
+	for i, v := range variants {
+		srcset[i] = imageURL(v.URL) + " " + strconv.Itoa(v.Width) + "w"
+	}
+	var alt strings.Builder
+	ast.WalkFunc(img, func(node ast.Node, entering bool) ast.WalkStatus {
+		if leaf := node.AsLeaf(); leaf != nil && entering {
+			alt.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	var tag strings.Builder
+	fmt.Fprintf(&tag, `<img src="%s" srcset="%s" sizes="%s" width="%d" height="%d" alt="%s"`,
+		html.EscapeString(imageURL(largest.URL)), html.EscapeString(strings.Join(srcset, ", ")),
+		html.EscapeString(sizes), largest.Width, largest.Height, html.EscapeString(alt.String()))
+	if img.Title != nil {
+		fmt.Fprintf(&tag, ` title="%s"`, html.EscapeString(string(img.Title)))
+	}
+	tag.WriteString(` loading="lazy" />`)
+	return tag.String()
+}
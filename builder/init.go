@@ -1,44 +1,141 @@
 package builder
 
 import (
-	"embed"
+	"errors"
 	"fmt"
-	"io/fs"
+	"net/url"
 	"os"
-	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/jmcharter/lumaca/config"
 )
 
-func Initialise(author string, title string) error {
+// InitOptions are the answers used to create a new project
+type InitOptions struct {
+	Title      string
+	Author     string
+	BaseURL    string
+	Language   string
+	Permalinks string
+	Theme      string
+}
+
+// Starter themes that can be installed by init
+var StarterThemes = []string{"default"}
+
+var languagePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// Fills in defaults for any options not given
+func (o InitOptions) WithDefaults() InitOptions {
+	if o.Author == "" {
+		o.Author = "Blog Author"
+	}
+	if o.Title == "" {
+		o.Title = "Blog Title"
+	}
+	if o.BaseURL == "" {
+		o.BaseURL = "https://example.com/"
+	}
+	if o.Language == "" {
+		o.Language = "en"
+	}
+	if o.Permalinks == "" {
+		o.Permalinks = PermalinkSlug
+	}
+	if o.Theme == "" {
+		o.Theme = StarterThemes[0]
+	}
+	return o
+}
+
+func (o InitOptions) Validate() error {
+	return errors.Join(
+		ValidateTitle(o.Title),
+		ValidateBaseURL(o.BaseURL),
+		ValidateLanguage(o.Language),
+		ValidatePermalinks(o.Permalinks),
+		ValidateTheme(o.Theme),
+	)
+}
+
+func ValidateTitle(title string) error {
+	if strings.TrimSpace(title) == "" {
+		return errors.New("title must not be empty")
+	}
+	return nil
+}
+
+func ValidateBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("base URL %q must be an absolute http or https URL, e.g. https://example.com/", baseURL)
+	}
+	return nil
+}
+
+func ValidateLanguage(language string) error {
+	if !languagePattern.MatchString(language) {
+		return fmt.Errorf("language %q must be a language code, e.g. en or en-gb", language)
+	}
+	return nil
+}
+
+func ValidatePermalinks(style string) error {
+	return validateChoice("permalink style", style, PermalinkStyles)
+}
+
+func ValidateTheme(theme string) error {
+	return validateChoice("theme", theme, StarterThemes)
+}
+
+func validateChoice(name string, value string, choices []string) error {
+	for _, c := range choices {
+		if value == c {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %q must be one of: %s", name, value, strings.Join(choices, ", "))
+}
+
+func Initialise(opts InitOptions) error {
 	// Check for existence of config.toml
 	if _, err := os.Stat("config.toml"); err == nil {
 		fmt.Println("Project already initialised")
 		return nil // Early return if file exists
 	}
-	// Create config.toml
-	if author == "" {
-		author = "Blog Author"
-	}
-	if title == "" {
-		title = "Blog Title"
+	opts = opts.WithDefaults()
+	if err := opts.Validate(); err != nil {
+		return err
 	}
+	// Create config.toml
 	var cfg_data config.Config
-	cfg_data.Author.Name = author
-	cfg_data.Site.Title = title
-	cfg_data.Site.Author = author
+	cfg_data.Author.Name = opts.Author
+	cfg_data.Site.Title = opts.Title
+	cfg_data.Site.Author = opts.Author
+	cfg_data.Site.BaseURL = opts.BaseURL
+	cfg_data.Site.Language = opts.Language
+	cfg_data.Site.Permalinks = opts.Permalinks
+	cfg_data.Site.DateFormat = defaultDateFormat
+	cfg_data.Site.WordsPerMinute = defaultWordsPerMinute
+	cfg_data.Site.Theme = opts.Theme
 	cfg_data.Directories.Posts = "content/posts"
 	cfg_data.Directories.Pages = "content/pages"
 	cfg_data.Directories.Static = "content/static"
 	cfg_data.Directories.Templates = "templates"
+	cfg_data.Directories.Archetypes = "archetypes"
+	cfg_data.Directories.I18n = "i18n"
+	cfg_data.Directories.Data = "data"
 	cfg_data.Directories.Dist = "dist"
 	cfg_data.Files.Extension = ".html"
+	cfg_data.Files.Frontmatter = "yaml"
 
 	f, err := os.Create("config.toml")
 	if err != nil {
 		return fmt.Errorf("failed to create config.toml: %w", err)
 	}
+	defer f.Close()
 	err = toml.NewEncoder(f).Encode(cfg_data)
 	if err != nil {
 		return fmt.Errorf("failed to write config data to config.toml: %w", err)
@@ -56,58 +153,31 @@ func Initialise(author string, title string) error {
 
 	err = os.MkdirAll(cfg_data.Directories.Posts, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create content/static directory: %w", err)
+		return fmt.Errorf("failed to create content/posts directory: %w", err)
 	}
 
-	// Copy embedded files
-	err = copyEmbeddedFiles(EmbeddedFiles, "templates", "templates")
+	err = os.MkdirAll(cfg_data.Directories.Pages, 0755)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to create content/pages directory: %w", err)
 	}
-	err = copyEmbeddedFiles(EmbeddedFiles, "content/static", "content/static")
+
+	err = os.MkdirAll(cfg_data.Directories.Data, 0755)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	return nil
-}
-
-func copyEmbeddedFiles(fsys embed.FS, sourceDir, targetDir string) error {
-	err := fs.WalkDir(fsys, sourceDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-
-		content, err := fsys.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read embedded file %s: %w", path, err)
-		}
-
-		relativePath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return err
-		}
-		targetPath := filepath.Join(targetDir, relativePath)
-
-		err = os.MkdirAll(filepath.Dir(targetPath), 0755)
-		if err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(targetPath), err)
-		}
-
-		err = os.WriteFile(targetPath, content, 0644)
-		if err != nil {
-			return fmt.Errorf("failed to write file %s: %w", targetPath, err)
-		}
-
-		fmt.Printf("Copied %s to %s\n", path, targetPath)
-		return nil
-	})
+	// The theme provides the templates, static files, archetypes and
+	// translations, so these directories start empty, ready for the
+	// project's own versions
+	err = os.MkdirAll(cfg_data.Directories.Archetypes, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create archetypes directory: %w", err)
+	}
 
+	err = os.MkdirAll(cfg_data.Directories.I18n, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to copy embedded files: %w", err)
+		return fmt.Errorf("failed to create i18n directory: %w", err)
 	}
+
 	return nil
 }
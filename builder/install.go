@@ -0,0 +1,331 @@
+package builder
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// A theme available to the site
+type ThemeInfo struct {
+	Name string
+	// The theme's directory, or empty for the built in theme
+	Dir string
+	// Whether the site uses the theme
+	Active bool
+}
+
+// Lists the built in theme and the themes in the project's themes directory
+func ListThemes(cfg config.Config) ([]ThemeInfo, error) {
+	var themes []ThemeInfo
+	if _, ok := localThemeDir(cfg, defaultTheme); !ok {
+		themes = append(themes, ThemeInfo{Name: defaultTheme, Active: cfg.Site.Theme == defaultTheme})
+	}
+	entries, err := os.ReadDir(getThemesDir(cfg))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read themes directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		themes = append(themes, ThemeInfo{
+			Name:   entry.Name(),
+			Dir:    filepath.Join(getThemesDir(cfg), entry.Name()),
+			Active: cfg.Site.Theme == entry.Name(),
+		})
+	}
+	sort.SliceStable(themes, func(i, j int) bool {
+		return themes[i].Name < themes[j].Name
+	})
+	return themes, nil
+}
+
+// Returns the name a theme is installed under by default, the name of the
+// directory or archive without its extension
+func themeName(src string) string {
+	name := filepath.Base(filepath.Clean(src))
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// Installs a theme from a directory or a zip, tar or gzipped tar archive
+// into the themes directory, under name or the name of the source if it is
+// empty. An archive holding a single directory has it unwrapped. Returns
+// the directory the theme was installed to.
+func InstallTheme(cfg config.Config, src string, name string, force bool) (string, error) {
+	if name == "" {
+		name = themeName(src)
+	}
+	if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("theme name %q must be a plain directory name", name)
+	}
+	themesDir := getThemesDir(cfg)
+	dest := filepath.Join(themesDir, name)
+	if _, err := os.Stat(dest); err == nil && !force {
+		return "", fmt.Errorf("theme %q is already installed in %s, use --force to replace it", name, dest)
+	}
+	if err := os.MkdirAll(themesDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create themes directory: %w", err)
+	}
+	// Unpack next to the destination, so a failed install leaves nothing
+	// behind and the result can be moved into place
+	tmp, err := os.MkdirTemp(themesDir, ".install-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read theme: %w", err)
+	}
+	lower := strings.ToLower(src)
+	switch {
+	case info.IsDir():
+		err = copyFS(os.DirFS(src), tmp)
+	case strings.HasSuffix(lower, ".zip"):
+		err = extractZip(src, tmp)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"), strings.HasSuffix(lower, ".tar"):
+		err = extractTar(src, tmp)
+	default:
+		err = fmt.Errorf("%s is not a directory, zip or tar archive", src)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	root, err := themeRoot(tmp)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a theme: %w", src, err)
+	}
+	if err := os.RemoveAll(dest); err != nil {
+		return "", fmt.Errorf("failed to remove existing theme: %w", err)
+	}
+	if err := os.Rename(root, dest); err != nil {
+		return "", fmt.Errorf("failed to install theme: %w", err)
+	}
+	return dest, nil
+}
+
+// Finds the directory holding a theme's templates, either dir itself or the
+// only directory in it
+func themeRoot(dir string) (string, error) {
+	if isDir(filepath.Join(dir, themeTemplates)) {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		inner := filepath.Join(dir, entries[0].Name())
+		if isDir(filepath.Join(inner, themeTemplates)) {
+			return inner, nil
+		}
+	}
+	return "", fmt.Errorf("no %s directory found", themeTemplates)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Returns where an archive entry is extracted to in dest, refusing entries
+// that would be written outside of it
+func extractPath(dest string, name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, `\`, "/"), "./"))
+	if clean == "." {
+		return dest, nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return "", fmt.Errorf("archive entry %q is outside of the archive", name)
+	}
+	return filepath.Join(dest, filepath.FromSlash(clean)), nil
+}
+
+func writeExtracted(target string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", target, err)
+	}
+	return nil
+}
+
+func extractZip(src string, dest string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		target, err := extractPath(dest, f.Name)
+		if err != nil {
+			return err
+		}
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read %s from zip archive: %w", f.Name, err)
+			}
+			err = writeExtracted(target, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			// Symlinks could point outside of the theme
+			fmt.Printf("Skipping %s, only files and directories are installed\n", f.Name)
+		}
+	}
+	return nil
+}
+
+func extractTar(src string, dest string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open tar archive: %w", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if !strings.HasSuffix(strings.ToLower(src), ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to decompress archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		target, err := extractPath(dest, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+		case tar.TypeReg:
+			if err := writeExtracted(target, tr); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader:
+			// Metadata written by git archive
+		default:
+			fmt.Printf("Skipping %s, only files and directories are installed\n", header.Name)
+		}
+	}
+}
+
+// Copies a file or directory of the site's theme into the project, so that
+// it can be customised, e.g. templates/post.html or static/css. Paths
+// without a theme directory are taken to be templates. Returns the files
+// written.
+func EjectThemeFile(cfg config.Config, file string, force bool) ([]string, error) {
+	if cfg.Site.Theme == "" {
+		return nil, errors.New("the site doesn't use a theme, set theme in the [site] section of config.toml")
+	}
+	file = path.Clean(filepath.ToSlash(file))
+	dir, rest, _ := strings.Cut(file, "/")
+	var projectDir string
+	switch dir {
+	case themeTemplates:
+		projectDir = cfg.Directories.Templates
+	case themeStatic:
+		projectDir = cfg.Directories.Static
+	case themeArchetypes:
+		projectDir = getArchetypesDir(cfg)
+	case themeI18n:
+		projectDir = getI18nDir(cfg)
+	default:
+		dir, rest = themeTemplates, file
+		projectDir = cfg.Directories.Templates
+	}
+	if projectDir == "" {
+		return nil, fmt.Errorf("directories.%s must be set to eject %s", dir, file)
+	}
+	if rest == "" {
+		rest = "."
+	}
+	if !fs.ValidPath(rest) {
+		return nil, fmt.Errorf("%s is not a path in the theme", file)
+	}
+	theme, err := themeDir(cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fs.Stat(theme, rest); err != nil {
+		return nil, fmt.Errorf("theme %q has no %s/%s", cfg.Site.Theme, dir, rest)
+	}
+
+	// Check every file first, so that nothing is copied if any would be
+	// overwritten
+	var files []string
+	err = fs.WalkDir(theme, rest, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		target := filepath.Join(projectDir, filepath.FromSlash(p))
+		if _, err := os.Stat(target); err == nil && !force {
+			return fmt.Errorf("%s already exists, use --force to replace it", target)
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var written []string
+	for _, p := range files {
+		target := filepath.Join(projectDir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return written, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := copyFSFile(theme, p, target); err != nil {
+			return written, err
+		}
+		written = append(written, target)
+	}
+	return written, nil
+}
@@ -0,0 +1,176 @@
+package builder
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// Language is a language the site is built in
+type Language struct {
+	Code   string
+	Name   string
+	Title  string
+	Weight int
+	// Directory holding content in this language, if any
+	ContentDir string
+	// Whether this is the default language, which is built at the site root
+	// rather than under a directory named after its code
+	IsDefault bool
+}
+
+// Translation links to a version of some content in another language
+type Translation struct {
+	Language string
+	Name     string
+	Title    string
+	Path     string
+	// The absolute URL, if the site has a base URL
+	URL string
+}
+
+// Returns the site's languages, default first and the rest ordered by
+// weight. Sites without any languages configured have a single language.
+func getLanguages(config config.Config) []Language {
+	if len(config.Languages) == 0 {
+		return []Language{{
+			Code:      config.Site.Language,
+			Title:     config.Site.Title,
+			IsDefault: true,
+		}}
+	}
+	var languages []Language
+	for code, l := range config.Languages {
+		title := l.Title
+		if title == "" {
+			title = config.Site.Title
+		}
+		languages = append(languages, Language{
+			Code:       code,
+			Name:       l.Name,
+			Title:      title,
+			Weight:     l.Weight,
+			ContentDir: l.ContentDir,
+		})
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		a, b := languages[i], languages[j]
+		if (a.Code == config.Site.Language) != (b.Code == config.Site.Language) {
+			return a.Code == config.Site.Language
+		}
+		if a.Weight != b.Weight {
+			return a.Weight < b.Weight
+		}
+		return a.Code < b.Code
+	})
+	languages[0].IsDefault = true
+	return languages
+}
+
+// The directory content in a language is written to
+func getLanguageDir(config config.Config, lang Language) string {
+	if lang.IsDefault {
+		return config.Directories.Dist
+	}
+	return filepath.Join(config.Directories.Dist, lang.Code)
+}
+
+func getHomePath(lang Language) string {
+	if lang.IsDefault {
+		return "/"
+	}
+	return "/" + lang.Code + "/"
+}
+
+func findLanguage(languages []Language, code string) Language {
+	for _, l := range languages {
+		if l.Code == code {
+			return l
+		}
+	}
+	return languages[0]
+}
+
+// Splits a language suffix from a content file name, e.g. my-post.fr.md is
+// my-post in French. Files without a suffix naming one of the site's
+// languages are in defaultCode.
+func splitLanguageSuffix(fileName string, languages []Language, defaultCode string) (string, string) {
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	ext := filepath.Ext(base)
+	if ext == "" || len(languages) < 2 {
+		return base, defaultCode
+	}
+	code := strings.TrimPrefix(ext, ".")
+	for _, l := range languages {
+		if strings.EqualFold(l.Code, code) {
+			return strings.TrimSuffix(base, ext), l.Code
+		}
+	}
+	return base, defaultCode
+}
+
+// Links each piece of content to its versions in other languages, matched by
+// translation key
+func linkTranslations(config config.Config, mds []MarkdownData, languages []Language) {
+	byKey := make(map[string][]int)
+	for i, md := range mds {
+		byKey[md.translationKey] = append(byKey[md.translationKey], i)
+	}
+	for i := range mds {
+		mds[i].Translations = nil
+		for _, lang := range languages {
+			for _, j := range byKey[mds[i].translationKey] {
+				other := mds[j]
+				if j == i || other.Language != lang.Code || lang.Code == mds[i].Language {
+					continue
+				}
+				mds[i].Translations = append(mds[i].Translations, Translation{
+					Language: lang.Code,
+					Name:     lang.Name,
+					Title:    other.Frontmatter.Title,
+					Path:     other.Path,
+					URL:      absURL(config, other.Path),
+				})
+			}
+		}
+	}
+}
+
+// Links to the home page of each language other than lang
+func homeTranslations(config config.Config, lang Language, languages []Language) []Translation {
+	var translations []Translation
+	for _, l := range languages {
+		if l.Code == lang.Code {
+			continue
+		}
+		path := getHomePath(l)
+		translations = append(translations, Translation{
+			Language: l.Code,
+			Name:     l.Name,
+			Title:    l.Title,
+			Path:     path,
+			URL:      absURL(config, path),
+		})
+	}
+	return translations
+}
+
+func filterLanguage(mds []MarkdownData, code string) []MarkdownData {
+	var filtered []MarkdownData
+	for _, md := range mds {
+		if md.Language == code {
+			filtered = append(filtered, md)
+		}
+	}
+	return filtered
+}
+
+// Joins a site root relative path to the base URL, if there is one
+func absURL(config config.Config, path string) string {
+	if config.Site.BaseURL == "" {
+		return path
+	}
+	return strings.TrimSuffix(config.Site.BaseURL, "/") + path
+}
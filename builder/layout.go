@@ -0,0 +1,122 @@
+package builder
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// The name of the file in the content directory giving the home page's content
+const homeContentFile = "_index.md"
+
+func templateExists(config config.Config, name string) bool {
+	fsys, err := layeredDir(config, themeTemplates, config.Directories.Templates)
+	if err != nil {
+		return false
+	}
+	info, err := fs.Stat(fsys, getTemplateFileName(config, name))
+	return err == nil && !info.IsDir()
+}
+
+// Returns the name of the template to render content with, the first that
+// exists of its layout, its type, its section and the default for the
+// content type, e.g. for a post: gallery, post, posts, post. Content in
+// other sections is rendered like posts, e.g. talk, then post. The home page
+// is given a list of posts rather than one piece of content, so it only
+// uses its layout and home.
+func lookupLayout(config config.Config, md MarkdownData, cType contentType) string {
+	var names []string
+	layout := strings.TrimSuffix(md.Frontmatter.Layout, config.Files.Extension)
+	if layout != "" && !filepath.IsLocal(layout) {
+		fmt.Printf("Ignoring layout %q of %q, it must be a template in the templates directory\n", md.Frontmatter.Layout, md.Frontmatter.Title)
+		layout = ""
+	}
+	if cType == contentTypeHome && (layout == contentTypePost.String() || layout == contentTypePage.String()) {
+		fmt.Printf("Ignoring layout %q of the home page, %s templates render a single %s\n", md.Frontmatter.Layout, layout, layout)
+		layout = ""
+	}
+	if layout != "" {
+		names = append(names, layout)
+	}
+	if cType != contentTypeHome {
+		// base and home aren't complete templates for content
+		if t := md.Frontmatter.Type; t == contentTypePost || t == contentTypePage {
+			names = append(names, t.String())
+		}
+		if md.section != "" {
+			names = append(names, md.section)
+		}
+	}
+	names = append(names, cType.String())
+	for _, name := range names {
+		if !templateExists(config, name) {
+			continue
+		}
+		if layout != "" && name != layout {
+			fmt.Printf("Layout %q of %q not found, using %s\n", md.Frontmatter.Layout, md.Frontmatter.Title, name)
+		}
+		return name
+	}
+	return cType.String()
+}
+
+// Reads the home page content of each language from _index.md in the
+// content directory. Other languages use _index.<code>.md, or _index.md in
+// their content_dir.
+func loadHomeContent(config config.Config, languages []Language, images *imageProcessor, opts BuildOptions) (map[string]MarkdownData, error) {
+	contentDir := filepath.Dir(config.Directories.Posts)
+	paths, err := filepath.Glob(filepath.Join(contentDir, "_index*.md"))
+	if err != nil {
+		return nil, err
+	}
+	for _, lang := range languages {
+		if !lang.IsDefault && lang.ContentDir != "" {
+			paths = append(paths, filepath.Join(lang.ContentDir, homeContentFile))
+		}
+	}
+	var mds []MarkdownData
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read home page content: %w", err)
+		}
+		matter, content, err := parseMatter(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse frontmatter from %s: %w", path, err)
+		}
+		md := MarkdownData{
+			Frontmatter: matter,
+			Content:     content,
+			sourcePath:  path,
+		}
+		var name string
+		name, md.Language = splitLanguageSuffix(filepath.Base(path), languages, languages[0].Code)
+		if filepath.Dir(path) != contentDir {
+			for _, lang := range languages {
+				if filepath.Join(lang.ContentDir, homeContentFile) == path {
+					md.Language = lang.Code
+				}
+			}
+		} else if name != strings.TrimSuffix(homeContentFile, ".md") {
+			// e.g. _index.draft.md, not a language suffix
+			continue
+		}
+		md.Path = getHomePath(findLanguage(languages, md.Language))
+		mds = append(mds, md)
+	}
+	mds = filterPublished(mds, opts)
+	mds = RenderAllMDToHTML(mds, images)
+	addReadingStats(mds, config.Site.WordsPerMinute)
+	home := make(map[string]MarkdownData, len(mds))
+	for _, md := range mds {
+		home[md.Language] = md
+	}
+	return home, nil
+}
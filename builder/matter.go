@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/adrg/frontmatter"
+)
+
+// Decodes the frontmatter of a content file into a Matter, keeping any keys
+// not known to Matter in its Params, and returns the remaining content
+func parseMatter(data []byte) (Matter, []byte, error) {
+	var matter Matter
+	content, err := frontmatter.Parse(bytes.NewReader(data), &matter)
+	if err != nil {
+		return Matter{}, nil, err
+	}
+	if matter.Lastmod.IsZero() {
+		matter.Lastmod = matter.Updated
+	}
+	var raw map[string]any
+	_, err = frontmatter.Parse(bytes.NewReader(data), &raw)
+	if err != nil {
+		return Matter{}, nil, err
+	}
+	known := matterKeys()
+	for k, v := range raw {
+		if known[strings.ToLower(k)] {
+			continue
+		}
+		if matter.Params == nil {
+			matter.Params = make(map[string]any)
+		}
+		matter.Params[k] = normaliseParam(v)
+	}
+	return matter, content, nil
+}
+
+// Returns the set of frontmatter keys decoded into Matter's own fields
+func matterKeys() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(Matter{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		keys[name] = true
+	}
+	return keys
+}
+
+// YAML decodes nested maps with interface{} keys, which templates can't
+// address with dot notation, so they are converted to string keyed maps
+func normaliseParam(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = normaliseParam(val)
+		}
+		return m
+	case map[string]any:
+		for k, val := range v {
+			v[k] = normaliseParam(val)
+		}
+		return v
+	case []any:
+		for i, val := range v {
+			v[i] = normaliseParam(val)
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,211 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// A link in a navigation menu, as passed to templates
+type MenuEntry struct {
+	Name       string
+	URL        string
+	Weight     int
+	Identifier string
+	Children   []MenuEntry
+
+	parent string
+	// The file name pages are written to in their own directory, e.g.
+	// index.html, which links to the directory may leave off
+	indexFile string
+}
+
+// Reports whether the entry links to another site
+func (e MenuEntry) IsExternal() bool {
+	u, err := url.Parse(e.URL)
+	return err == nil && u.Host != ""
+}
+
+func (e MenuEntry) HasChildren() bool {
+	return len(e.Children) > 0
+}
+
+// Reports whether the entry, or one nested under it, links to the page at
+// path, e.g. {{ if .IsActive $.Path }}
+func (e MenuEntry) IsActive(path string) bool {
+	if e.URL != "" && !e.IsExternal() && samePath(e.URL, path, e.indexFile) {
+		return true
+	}
+	for _, child := range e.Children {
+		if child.IsActive(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether two URL paths are the same page, treating a directory and
+// its index file as the same
+func samePath(a, b, indexFile string) bool {
+	a = strings.TrimSuffix(strings.TrimSuffix(a, indexFile), "/")
+	b = strings.TrimSuffix(strings.TrimSuffix(b, indexFile), "/")
+	return a == b
+}
+
+// The menus a piece of content is listed in. Frontmatter can give a single
+// menu name or a list of them.
+type menuNames []string
+
+func (m *menuNames) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*m = menuNames{name}
+		return nil
+	}
+	var names []string
+	if err := unmarshal(&names); err != nil {
+		return fmt.Errorf("menu must be a name or a list of names")
+	}
+	*m = names
+	return nil
+}
+
+func (m *menuNames) UnmarshalTOML(v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		*m = menuNames{v}
+		return nil
+	case []interface{}:
+		names := make(menuNames, 0, len(v))
+		for _, name := range v {
+			s, ok := name.(string)
+			if !ok {
+				return fmt.Errorf("menu must be a name or a list of names")
+			}
+			names = append(names, s)
+		}
+		*m = names
+		return nil
+	}
+	return fmt.Errorf("menu must be a name or a list of names")
+}
+
+func (m *menuNames) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*m = menuNames{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("menu must be a name or a list of names")
+	}
+	*m = names
+	return nil
+}
+
+// Returns the URL of a menu entry from the config in a language. Paths on
+// the site are moved under the language's directory, e.g. /about.html is
+// /fr/about.html in French, apart from static files, which every language
+// shares.
+func localizeMenuURL(cfg config.Config, lang Language, u string) string {
+	home := getHomePath(lang)
+	if lang.IsDefault || !strings.HasPrefix(u, "/") || strings.HasPrefix(u, "//") ||
+		strings.HasPrefix(u, home) || strings.HasPrefix(u, staticURLPrefix(cfg)) {
+		return u
+	}
+	return strings.TrimSuffix(home, "/") + u
+}
+
+// Builds the menus of a language from the config and the content that lists
+// itself in a menu in its frontmatter, nesting entries under their parents
+func buildMenus(cfg config.Config, lang Language, content ...[]MarkdownData) map[string][]MenuEntry {
+	flat := make(map[string][]MenuEntry)
+	indexFile := "index" + cfg.Files.Extension
+	for name, entries := range cfg.Menus {
+		for _, e := range entries {
+			id := e.Identifier
+			if id == "" {
+				id = e.Name
+			}
+			flat[name] = append(flat[name], MenuEntry{
+				Name:       e.Name,
+				URL:        localizeMenuURL(cfg, lang, e.URL),
+				Weight:     e.Weight,
+				Identifier: id,
+				parent:     e.Parent,
+				indexFile:  indexFile,
+			})
+		}
+	}
+	for _, mds := range content {
+		for _, md := range mds {
+			for _, name := range md.Frontmatter.Menu {
+				flat[name] = append(flat[name], MenuEntry{
+					Name:       md.Frontmatter.Title,
+					URL:        md.Path,
+					Weight:     md.Frontmatter.Weight,
+					Identifier: md.translationKey,
+					parent:     md.Frontmatter.MenuParent,
+					indexFile:  indexFile,
+				})
+			}
+		}
+	}
+	menus := make(map[string][]MenuEntry, len(flat))
+	for name, entries := range flat {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].Weight != entries[j].Weight {
+				return entries[i].Weight < entries[j].Weight
+			}
+			return entries[i].Name < entries[j].Name
+		})
+		// The lightest of entries sharing an identifier is kept, as the
+		// others couldn't be told apart as parents
+		ids := make(map[string]bool, len(entries))
+		unique := entries[:0]
+		for _, e := range entries {
+			if ids[e.Identifier] {
+				fmt.Printf("Menu %s: %q has the same identifier as another entry, leaving it out\n", name, e.Name)
+				continue
+			}
+			ids[e.Identifier] = true
+			unique = append(unique, e)
+		}
+		entries = unique
+		for i, e := range entries {
+			if e.parent != "" && (!ids[e.parent] || e.parent == e.Identifier) {
+				fmt.Printf("Menu %s: parent %q of %q not found, adding it at the top level\n", name, e.parent, e.Name)
+				entries[i].parent = ""
+			}
+		}
+		placed := make(map[string]bool, len(entries))
+		menus[name] = menuChildren(entries, "", placed)
+		for _, e := range entries {
+			if !placed[e.Identifier] {
+				fmt.Printf("Menu %s: the parents of %q loop back to it, leaving it out\n", name, e.Name)
+			}
+		}
+	}
+	return menus
+}
+
+// Returns the entries nested directly under parent, with their own
+// children. Entries already placed are skipped so that a loop of parents
+// can't recurse forever.
+func menuChildren(entries []MenuEntry, parent string, placed map[string]bool) []MenuEntry {
+	var children []MenuEntry
+	for _, e := range entries {
+		if e.parent != parent || placed[e.Identifier] {
+			continue
+		}
+		placed[e.Identifier] = true
+		e.Children = menuChildren(entries, e.Identifier, placed)
+		children = append(children, e)
+	}
+	return children
+}
@@ -0,0 +1,541 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// Returns the index after the string starting with the quote at src[i],
+// skipping escaped quotes
+func stringEnd(src []byte, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			// Unterminated, stop at the end of the line
+			return j
+		}
+	}
+	return len(src)
+}
+
+// Returns the index after the /* */ comment starting at src[i]
+func commentEnd(src []byte, i int) int {
+	end := bytes.Index(src[i+2:], []byte("*/"))
+	if end < 0 {
+		return len(src)
+	}
+	return i + 2 + end + 2
+}
+
+// Reports whether the comment starting at src[i] is kept by minification,
+// as comments starting /*! usually hold licences
+func keptComment(src []byte, i int) bool {
+	return i+2 < len(src) && src[i+2] == '!'
+}
+
+// Minifies CSS by removing comments and the whitespace that doesn't change
+// its meaning
+func minifyCSS(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	space := false
+	depth := 0
+	for i := 0; i < len(src); {
+		c := src[i]
+		end := i + 1
+		switch {
+		case isSpaceByte(c):
+			space = true
+			i++
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end = commentEnd(src, i)
+			if !keptComment(src, i) {
+				space = true
+				i = end
+				continue
+			}
+		case c == '"' || c == '\'':
+			end = stringEnd(src, i)
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		}
+		// Spaces are only needed between words, e.g. "solid 1px" or
+		// "a :hover", not next to punctuation or the colon of a declaration
+		if space && len(out) > 0 && !strings.ContainsRune("{};,:", rune(out[len(out)-1])) && !strings.ContainsRune("{};,", rune(c)) &&
+			!(c == ':' && depth > 0 && isDeclaration(src, i)) {
+			out = append(out, ' ')
+		}
+		space = false
+		if c == '}' && len(out) > 0 && out[len(out)-1] == ';' {
+			out = out[:len(out)-1]
+		}
+		out = append(out, src[i:end]...)
+		i = end
+	}
+	return out
+}
+
+// Reports whether the colon at src[i], inside a block, separates a property
+// from its value rather than being part of a nested selector such as
+// "a :hover", which is followed by a block
+func isDeclaration(src []byte, i int) bool {
+	for j := i + 1; j < len(src); {
+		switch src[j] {
+		case ';', '}':
+			return true
+		case '{':
+			return false
+		case '"', '\'':
+			j = stringEnd(src, j)
+			continue
+		}
+		j++
+	}
+	return true
+}
+
+// Words after which a / starts a regular expression rather than dividing
+var regexpKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true,
+	"in": true, "of": true, "void": true, "yield": true, "await": true,
+	"delete": true, "throw": true, "instanceof": true, "new": true,
+}
+
+// Statements whose condition in brackets can be followed by a regular
+// expression, e.g. if (a) /b/.test(c)
+var controlKeywords = map[string]bool{
+	"if": true, "while": true, "for": true, "with": true,
+}
+
+// Returns the word at the end of out, ignoring whitespace after it
+func lastWord(out []byte) string {
+	end := len(out)
+	for end > 0 && isSpaceByte(out[end-1]) {
+		end--
+	}
+	start := end
+	for start > 0 && isWordByte(out[start-1]) {
+		start--
+	}
+	return string(out[start:end])
+}
+
+// Reports whether a / after out starts a regular expression. afterControl
+// is whether the last ) closed the condition of an if, while or for.
+func startsRegexp(out []byte, afterControl bool) bool {
+	end := len(out)
+	for end > 0 && isSpaceByte(out[end-1]) {
+		end--
+	}
+	if end == 0 {
+		return true
+	}
+	switch last := out[end-1]; {
+	case last == ')':
+		return afterControl
+	case last == ']' || last == '}':
+		return false
+	case !isWordByte(last):
+		return true
+	}
+	return regexpKeywords[lastWord(out)]
+}
+
+// Returns the index after the regular expression starting at src[i]
+func regexpEnd(src []byte, i int) int {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				return j + 1
+			}
+		case '\n':
+			return j
+		}
+	}
+	return len(src)
+}
+
+// Minifies JavaScript by removing comments, indentation and blank lines.
+// Line breaks within code are kept, so that automatic semicolon insertion
+// still applies.
+func minifyJS(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	space, newline := false, false
+	// Open braces in each template literal ${} expression being read
+	var templates []int
+	// Whether each open bracket holds the condition of an if, while or for,
+	// and whether the last one closed did
+	var parens []bool
+	afterControl := false
+	for i := 0; i < len(src); {
+		c := src[i]
+		end := i + 1
+		switch {
+		case isSpaceByte(c):
+			space = true
+			newline = newline || c == '\n'
+			i++
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			end = bytes.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i
+			}
+			space = true
+			i = end
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end = commentEnd(src, i)
+			if !keptComment(src, i) {
+				space = true
+				newline = newline || bytes.IndexByte(src[i:end], '\n') >= 0
+				i = end
+				continue
+			}
+		case c == '/' && startsRegexp(out, afterControl):
+			end = regexpEnd(src, i)
+		case c == '(':
+			parens = append(parens, controlKeywords[lastWord(out)])
+		case c == ')' && len(parens) > 0:
+			afterControl = parens[len(parens)-1]
+			parens = parens[:len(parens)-1]
+		case c == '"' || c == '\'':
+			end = stringEnd(src, i)
+		case c == '`':
+			end, templates = templateEnd(src, i+1, templates)
+		case c == '{' && len(templates) > 0:
+			templates[len(templates)-1]++
+		case c == '}' && len(templates) > 0:
+			if templates[len(templates)-1] == 0 {
+				// The end of a ${} expression, the template continues
+				templates = templates[:len(templates)-1]
+				end, templates = templateEnd(src, i+1, templates)
+			} else {
+				templates[len(templates)-1]--
+			}
+		}
+		if space && len(out) > 0 {
+			last := out[len(out)-1]
+			switch {
+			case newline && !strings.ContainsRune("{;,(\n", rune(last)):
+				out = append(out, '\n')
+			case isWordByte(last) && isWordByte(c),
+				// e.g. a - -b and a + +b
+				last == c && strings.ContainsRune("+-/", rune(c)):
+				out = append(out, ' ')
+			}
+		}
+		space, newline = false, false
+		out = append(out, src[i:end]...)
+		i = end
+	}
+	return out
+}
+
+// Returns the index after the part of a template literal starting at
+// src[i], either at its closing ` or after a ${ that starts an expression,
+// which is pushed onto templates
+func templateEnd(src []byte, i int, templates []int) (int, []int) {
+	for j := i; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '`':
+			return j + 1, templates
+		case '$':
+			if j+1 < len(src) && src[j+1] == '{' {
+				return j + 2, append(templates, 0)
+			}
+		}
+	}
+	return len(src), templates
+}
+
+// Elements that whitespace around doesn't show next to, as they aren't
+// laid out inline
+var blockElements = map[string]bool{
+	"!doctype": true, "html": true, "head": true, "body": true, "title": true,
+	"meta": true, "link": true, "base": true, "script": true, "style": true,
+	"noscript": true, "div": true, "p": true, "ul": true, "ol": true, "li": true,
+	"dl": true, "dt": true, "dd": true, "nav": true, "header": true,
+	"footer": true, "main": true, "section": true, "article": true,
+	"aside": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "table": true, "thead": true, "tbody": true, "tfoot": true,
+	"tr": true, "td": true, "th": true, "caption": true, "form": true,
+	"fieldset": true, "legend": true, "hr": true, "br": true, "blockquote": true,
+	"figure": true, "figcaption": true, "pre": true, "address": true,
+	"details": true, "summary": true,
+}
+
+// Returns the lower case name of the tag starting at src[i], without the /
+// of closing tags
+func tagName(src []byte, i int) string {
+	j := i + 1
+	if j < len(src) && (src[j] == '/' || src[j] == '!') {
+		if src[j] == '!' {
+			j++
+		} else {
+			i++
+			j++
+		}
+	}
+	for j < len(src) && !isSpaceByte(src[j]) && src[j] != '>' && src[j] != '/' {
+		j++
+	}
+	return strings.ToLower(string(src[i+1 : j]))
+}
+
+// Returns a tag starting at src[i] with the whitespace between its
+// attributes collapsed, and the index after it
+func minifyTag(src []byte, i int) ([]byte, int) {
+	var tag []byte
+	space := false
+	for j := i; j < len(src); j++ {
+		c := src[j]
+		switch {
+		case isSpaceByte(c):
+			space = true
+			continue
+		case (c == '"' || c == '\'') && len(tag) > 0 && tag[len(tag)-1] == '=':
+			end := bytes.IndexByte(src[j+1:], c)
+			if end < 0 {
+				return append(tag, src[j:]...), len(src)
+			}
+			tag = append(tag, src[j:j+end+2]...)
+			j += end + 1
+			space = false
+			continue
+		}
+		if space && c != '>' && c != '=' && tag[len(tag)-1] != '=' {
+			tag = append(tag, ' ')
+		}
+		space = false
+		tag = append(tag, c)
+		if c == '>' {
+			return tag, j + 1
+		}
+	}
+	return tag, len(src)
+}
+
+// Returns the index of the closing tag of a raw text element such as
+// script, or the end of src if it isn't closed
+func rawTextEnd(src []byte, i int, name string) int {
+	end := bytes.Index(bytes.ToLower(src[i:]), []byte("</"+name))
+	if end < 0 {
+		return len(src)
+	}
+	return i + end
+}
+
+// Reports whether a script tag holds JavaScript, rather than data such as
+// JSON
+func isJavaScript(tag []byte) bool {
+	lower := strings.ToLower(string(tag))
+	i := strings.Index(lower, " type=")
+	if i < 0 {
+		return true
+	}
+	value := strings.TrimLeft(lower[i+len(" type="):], `"'`)
+	if end := strings.IndexAny(value, `"' >`); end >= 0 {
+		value = value[:end]
+	}
+	return value == "" || value == "module" || strings.Contains(value, "javascript")
+}
+
+// Minifies HTML by removing comments and collapsing whitespace. Whitespace
+// next to block elements such as p and div is removed, and elsewhere is
+// kept as a single space, as it shows between inline elements. The content
+// of pre and textarea is left as it is, and that of script and style is
+// minified as JavaScript and CSS.
+func minifyHTML(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	space := false
+	// Whether the last thing written was a block element's tag
+	afterBlock := true
+	for i := 0; i < len(src); {
+		c := src[i]
+		if isSpaceByte(c) {
+			space = true
+			i++
+			continue
+		}
+		if bytes.HasPrefix(src[i:], []byte("<!--")) && !bytes.HasPrefix(src[i:], []byte("<!--[if")) {
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += 4 + end + 3
+			}
+			continue
+		}
+		isTag := c == '<' && i+1 < len(src) && (src[i+1] == '/' || src[i+1] == '!' || src[i+1] == '?' ||
+			('a' <= src[i+1] && src[i+1] <= 'z') || ('A' <= src[i+1] && src[i+1] <= 'Z'))
+		var name string
+		if isTag {
+			name = tagName(src, i)
+		}
+		block := isTag && blockElements[name]
+		if space && !afterBlock && !block {
+			out = append(out, ' ')
+		}
+		space = false
+		if !isTag {
+			end := i + 1
+			for end < len(src) && src[end] != '<' && !isSpaceByte(src[end]) {
+				end++
+			}
+			out = append(out, src[i:end]...)
+			afterBlock = false
+			i = end
+			continue
+		}
+		closing := src[i+1] == '/'
+		tag, end := minifyTag(src, i)
+		out = append(out, tag...)
+		afterBlock = block
+		i = end
+		switch name {
+		case "pre", "textarea", "script", "style":
+			if closing || bytes.HasSuffix(tag, []byte("/>")) {
+				break
+			}
+			rawEnd := rawTextEnd(src, i, name)
+			raw := src[i:rawEnd]
+			switch {
+			case name == "style":
+				raw = minifyCSS(raw)
+			case name == "script" && isJavaScript(tag):
+				raw = minifyJS(raw)
+			}
+			out = append(out, raw...)
+			// Whitespace shows at the end of pre and textarea, but not after
+			// their closing tags, which are block elements
+			afterBlock = false
+			i = rawEnd
+		}
+	}
+	return out
+}
+
+// Minifies XML, such as feeds and sitemaps, by removing comments and the
+// whitespace between tags. Text is left as it is.
+func minifyXML(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	for i := 0; i < len(src); {
+		switch {
+		case bytes.HasPrefix(src[i:], []byte("<!--")):
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				return out
+			}
+			i += 4 + end + 3
+		case bytes.HasPrefix(src[i:], []byte("<![CDATA[")):
+			end := bytes.Index(src[i:], []byte("]]>"))
+			if end < 0 {
+				return append(out, src[i:]...)
+			}
+			out = append(out, src[i:i+end+3]...)
+			i += end + 3
+		case src[i] == '<':
+			tag, end := minifyTag(src, i)
+			out = append(out, tag...)
+			i = end
+		default:
+			end := bytes.IndexByte(src[i:], '<')
+			if end < 0 {
+				end = len(src) - i
+			}
+			// Text that is only whitespace indents tags
+			if text := src[i : i+end]; len(bytes.TrimSpace(text)) > 0 {
+				out = append(out, text...)
+			}
+			i += end
+		}
+	}
+	return out
+}
+
+// Minifies the HTML and XML files in the output directory in place, other
+// than those copied from the static directory, and reports the bytes saved
+func minifyOutput(config config.Config) error {
+	var files, before, after int
+	static := getStaticOutputDir(config)
+	err := filepath.WalkDir(config.Directories.Dist, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Static files are published as they are
+		if d.IsDir() && path == static {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+		var minify func([]byte) []byte
+		switch strings.ToLower(filepath.Ext(path)) {
+		case strings.ToLower(config.Files.Extension), ".html", ".htm":
+			minify = minifyHTML
+		case ".xml":
+			minify = minifyXML
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read output file: %w", err)
+		}
+		minified := minify(data)
+		files++
+		before += len(data)
+		after += len(minified)
+		if len(minified) == len(data) {
+			return nil
+		}
+		if err := os.WriteFile(path, minified, 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to minify output: %w", err)
+	}
+	var percent float64
+	if before > 0 {
+		percent = float64(before-after) / float64(before) * 100
+	}
+	fmt.Printf("Minified %d files from %d to %d bytes, saving %d bytes (%.1f%%)\n", files, before, after, before-after, percent)
+	return nil
+}
@@ -0,0 +1,149 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+func TestMinifyCSS(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "whitespace", src: "a {\n  color: red;\n  margin: 0 auto;\n}\n", want: "a{color:red;margin:0 auto}"},
+		{name: "space before a colon", src: "a { color :red; }", want: "a{color:red}"},
+		{name: "descendant pseudo-class", src: "a :hover { color: red }", want: "a :hover{color:red}"},
+		{name: "nested descendant pseudo-class", src: "@media print { a :hover { color : red } }", want: "@media print{a :hover{color:red}}"},
+		{name: "comments", src: "/* reset */\na { /* inner */ margin: 0 }", want: "a{margin:0}"},
+		{name: "licence comment", src: "/*! MIT */\na { margin: 0 }", want: "/*! MIT */ a{margin:0}"},
+		{name: "strings", src: `a::after { content: "a  ;  }  /* b */" }`, want: `a::after{content:"a  ;  }  /* b */"}`},
+		{name: "string with a brace before a colon", src: `a { content :"{" }`, want: `a{content:"{"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(minifyCSS([]byte(tt.src))); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyJS(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "indentation", src: "function f(a) {\n    return a + 1;\n}\n", want: "function f(a){return a+1;}"},
+		{name: "line breaks are kept", src: "let a = 1\nlet b = 2\n", want: "let a=1\nlet b=2"},
+		{name: "comments", src: "// one\nlet a = 1 /* two */\n/* three\n */ let b = 2", want: "let a=1\nlet b=2"},
+		{name: "licence comment", src: "/*! MIT */\nlet a", want: "/*! MIT */\nlet a"},
+		{name: "unary operators", src: "a - -b + +c", want: "a- -b+ +c"},
+
+		{name: "string", src: `let s = "a  // b"  ;`, want: `let s="a  // b";`},
+		{name: "string with an escaped quote", src: `let s = 'it\'s  /* x */'`, want: `let s='it\'s  /* x */'`},
+
+		{name: "regexp", src: "let re = /a  b\\/ c/g;", want: "let re=/a  b\\/ c/g;"},
+		{name: "regexp with a slash in a class", src: "let re = /[/]  x/;", want: "let re=/[/]  x/;"},
+		{name: "regexp after return", src: "return /a  b/.test(s)", want: "return/a  b/.test(s)"},
+		{name: "regexp after an if", src: "if (a) /x  y/.test(s)", want: "if(a)/x  y/.test(s)"},
+		{name: "regexp after a nested condition", src: "while (f(a)) /x  y/.exec(s)", want: "while(f(a))/x  y/.exec(s)"},
+		{name: "division after brackets", src: "let x = (a + b) / 2 / c", want: "let x=(a+b)/2/c"},
+		{name: "division after a call", src: "let x = f(a) / 2 // half", want: "let x=f(a)/2"},
+		{name: "division after an index", src: "let x = a[0] / b[1] /* ratio */", want: "let x=a[0]/b[1]"},
+
+		{name: "template literal", src: "let s = `a  // b\n  c`", want: "let s=`a  // b\n  c`"},
+		{name: "template literal expression", src: "let s = `a  ${ b  +  c }  d`", want: "let s=`a  ${b+c}  d`"},
+		{name: "template literal with an object", src: "let s = `${ f({ a: 1 }) }  x  `", want: "let s=`${f({a:1})}  x  `"},
+		{name: "nested template literal", src: "let s = `a ${ `b  ${ c }` }  d`", want: "let s=`a ${`b  ${c}`}  d`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(minifyJS([]byte(tt.src))); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "block elements", src: "<div>\n  <p>Hello</p>\n</div>\n", want: "<div><p>Hello</p></div>"},
+		{name: "inline elements", src: "<p>Hello  <a href=\"/\">world</a>\n  !</p>", want: "<p>Hello <a href=\"/\">world</a> !</p>"},
+		{name: "attributes", src: "<a  href = \"/a  b\"\n   class='x'>a</a>", want: "<a href=\"/a  b\" class='x'>a</a>"},
+		{name: "comments", src: "<p>a<!-- note -->\n<!--\n more -->b</p>", want: "<p>a b</p>"},
+		{name: "conditional comments", src: "<head>\n<!--[if IE]>\n  <link rel=\"stylesheet\" href=\"ie.css\">\n<![endif]-->\n</head>", want: "<head><!--[if IE]><link rel=\"stylesheet\" href=\"ie.css\"><![endif]--></head>"},
+		{name: "pre", src: "<div>\n<pre>  a\n    b  </pre>\n</div>", want: "<div><pre>  a\n    b  </pre></div>"},
+		{name: "pre with markup", src: "<pre><code>if a  &lt; b {\n  <b>c</b>\n}</code></pre>", want: "<pre><code>if a  &lt; b {\n  <b>c</b>\n}</code></pre>"},
+		{name: "textarea", src: "<form>\n  <textarea name=\"t\">  a\n\n  b </textarea>\n</form>", want: "<form><textarea name=\"t\">  a\n\n  b </textarea></form>"},
+		{name: "upper case pre", src: "<PRE>  a  </PRE>", want: "<PRE>  a  </PRE>"},
+		{name: "style", src: "<style>\n  a { color : red; }\n</style>", want: "<style>a{color:red}</style>"},
+		{name: "script", src: "<script>\n  let a = 1;\n  // note\n</script>", want: "<script>let a=1;</script>"},
+		{name: "module script", src: "<script type=\"module\">\n  let a = 1;\n</script>", want: "<script type=\"module\">let a=1;</script>"},
+		{name: "JSON-LD script", src: "<script type=\"application/ld+json\">\n{ \"name\":  \"a // b\" }\n</script>", want: "<script type=\"application/ld+json\">\n{ \"name\":  \"a // b\" }\n</script>"},
+		{name: "template script", src: "<script type='text/template'>  <p>  a  </p>  </script>", want: "<script type='text/template'>  <p>  a  </p>  </script>"},
+		{name: "content after a script", src: "<script>\nlet s = \"a  b\";\n</script>\n<p> x </p>", want: "<script>let s=\"a  b\";</script><p>x</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(minifyHTML([]byte(tt.src))); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyXML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "indentation", src: "<?xml version=\"1.0\"?>\n<rss>\n  <channel>\n    <title>A  b</title>\n  </channel>\n</rss>\n", want: "<?xml version=\"1.0\"?><rss><channel><title>A  b</title></channel></rss>"},
+		{name: "comments", src: "<a>\n  <!-- note -->\n  <b>x</b>\n</a>", want: "<a><b>x</b></a>"},
+		{name: "CDATA", src: "<a>\n  <![CDATA[ <p>  x </p> <!-- y --> ]]>\n</a>", want: "<a><![CDATA[ <p>  x </p> <!-- y --> ]]></a>"},
+		{name: "unterminated CDATA", src: "<a><![CDATA[  x", want: "<a><![CDATA[  x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(minifyXML([]byte(tt.src))); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyOutputSkipsStatic(t *testing.T) {
+	var cfg config.Config
+	cfg.Directories.Dist = t.TempDir()
+	cfg.Directories.Static = "static"
+	cfg.Files.Extension = ".html"
+	page := filepath.Join(cfg.Directories.Dist, "index.html")
+	static := filepath.Join(cfg.Directories.Dist, "static", "demo.html")
+	src := "<div>\n  <p>a</p>\n</div>\n"
+	if err := os.MkdirAll(filepath.Dir(static), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{page, static} {
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := minifyOutput(cfg); err != nil {
+		t.Fatalf("failed to minify output: %v", err)
+	}
+	if got, _ := os.ReadFile(page); string(got) != "<div><p>a</p></div>" {
+		t.Errorf("page not minified, got %q", got)
+	}
+	if got, _ := os.ReadFile(static); string(got) != src {
+		t.Errorf("static file changed, got %q", got)
+	}
+}
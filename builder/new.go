@@ -1,54 +1,315 @@
 package builder
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/goccy/go-yaml"
 	"github.com/jmcharter/lumaca/config"
 )
 
-func New(cfg config.Config, title string, author string, draft bool) error {
+// NewOptions describe a new content file
+type NewOptions struct {
+	// The kind of content, e.g. post or page. Defaults to post.
+	Kind   string
+	Title  string
+	Author string
+	Draft  bool
+	Tags   []string
+	// The content's date. Defaults to the current time.
+	Date string
+	// A file or directory to create the content in, instead of the kind's
+	// content directory
+	Path string
+	// Overwrite the file if it already exists
+	Force bool
+}
+
+// Creates a new content file of the given kind from its archetype, and
+// returns its path. Projects without archetypes get a file with default
+// frontmatter.
+func New(cfg config.Config, opts NewOptions) (string, error) {
+	err := setSiteLocation(cfg)
+	if err != nil {
+		return "", err
+	}
+	kind := opts.Kind
+	if kind == "" {
+		kind = contentTypePost.String()
+	}
+	if !filepath.IsLocal(kind) || strings.ContainsAny(kind, `/\`) {
+		return "", fmt.Errorf("kind %q must be a plain name, e.g. post, page or talk", kind)
+	}
+	if kind != contentTypePost.String() && kind != contentTypePage.String() {
+		if _, ok := getSectionsDir(cfg); !ok {
+			return "", fmt.Errorf("kind %q can't be used, other kinds need the posts directory %s to be in a content directory, e.g. content/posts", kind, cfg.Directories.Posts)
+		}
+		if dir := getContentDir(cfg, kind); isReservedDir(cfg, dir) {
+			return "", fmt.Errorf("kind %q can't be used, %s isn't a content directory", kind, dir)
+		}
+	}
+	author := opts.Author
 	if author == "" {
 		author = cfg.Author.Name
 	}
-	metadata := Matter{
-		Title:   title,
-		Author:  author,
-		Tags:    []string{},
-		Date:    YAMLDate(time.Now()),
-		Type:    contentTypePost,
-		Slug:    newPostSlug(title),
-		IsDraft: draft,
+	date := time.Now().In(siteLocation).Truncate(time.Second)
+	if opts.Date != "" {
+		date, err = parseDate(opts.Date)
+		if err != nil {
+			return "", err
+		}
+	}
+	tags := opts.Tags
+	if tags == nil {
+		tags = []string{}
 	}
-	contentDir := cfg.Directories.Posts
-	filename := fmt.Sprintf("%s.md", metadata.Slug.String())
-	filepath := filepath.Join(contentDir, filename)
+	data := archetypeData{
+		Title:  opts.Title,
+		Slug:   newPostSlug(opts.Title).String(),
+		Author: author,
+		Date:   YAMLDate(date),
+		Type:   kind,
+		Draft:  opts.Draft,
+		Tags:   tags,
+	}
+	filePath := getNewFilePath(cfg, kind, data.Slug, opts.Path)
 
-	frontmatterBytes, err := yaml.Marshal(metadata)
+	archetypes, archetype, err := findArchetype(cfg, kind)
 	if err != nil {
-		return fmt.Errorf("failed to marshal frontmatter to YAML: %w", err)
+		return "", err
+	}
+	var initialContent []byte
+	if archetype != "" {
+		initialContent, err = renderArchetype(archetypes, archetype, data)
+		if err == nil {
+			initialContent, err = convertFrontmatter(initialContent, cfg.Files.Frontmatter)
+		}
+	} else {
+		initialContent, err = defaultContent(cfg, data)
+	}
+	if err != nil {
+		return "", err
 	}
-	frontmatter := fmt.Sprintf("---\n%s---\n\n", string(frontmatterBytes))
 
-	initialContent := frontmatter + "\n\nLorem ipsum..."
-	err = os.WriteFile(filepath, []byte(initialContent), os.ModePerm)
+	err = os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create content directory: %w", err)
+	}
+	err = writeNewFile(filePath, initialContent, opts.Force)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("New file created: %s\n", filePath)
+	return filePath, nil
+}
+
+// An explicit path ending in .md is used as is, any other path is treated as
+// the directory to create the content in
+func getNewFilePath(cfg config.Config, kind string, slug string, path string) string {
+	filename := fmt.Sprintf("%s.md", slug)
+	switch {
+	case path == "":
+		return filepath.Join(getContentDir(cfg, kind), filename)
+	case strings.EqualFold(filepath.Ext(path), ".md"):
+		return path
+	}
+	return filepath.Join(path, filename)
+}
+
+func writeNewFile(path string, content []byte, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create content file: %w", err)
 	}
-	fmt.Printf("New file created: %s", filepath)
+	defer f.Close()
+	_, err = f.Write(content)
+	if err != nil {
+		return fmt.Errorf("failed to write content file: %w", err)
+	}
 	return nil
 }
 
+func defaultContent(cfg config.Config, data archetypeData) ([]byte, error) {
+	metadata := Matter{
+		Title:   data.Title,
+		Author:  data.Author,
+		Tags:    data.Tags,
+		Date:    data.Date,
+		IsDraft: data.Draft,
+	}
+	switch data.Type {
+	case contentTypePost.String():
+		metadata.Type = contentTypePost
+	case contentTypePage.String():
+		metadata.Type = contentTypePage
+	}
+	frontmatter, err := marshalMatter(metadata, cfg.Files.Frontmatter)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(frontmatter + "\n\nLorem ipsum..."), nil
+}
+
+// Encodes frontmatter in the given format, wrapped in the delimiters that
+// frontmatter.Parse uses to detect it. Params follow Matter's own keys.
+func marshalMatter(matter Matter, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", "yaml":
+		b, err := yaml.Marshal(matter)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal frontmatter to YAML: %w", err)
+		}
+		if len(matter.Params) > 0 {
+			params, err := yaml.Marshal(matter.Params)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal frontmatter to YAML: %w", err)
+			}
+			b = append(b, params...)
+		}
+		return fmt.Sprintf("---\n%s---\n\n", string(b)), nil
+	case "toml":
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(matter); err != nil {
+			return "", fmt.Errorf("failed to marshal frontmatter to TOML: %w", err)
+		}
+		// Matter has no tables, so params can follow it, tables and all
+		if len(matter.Params) > 0 {
+			if err := toml.NewEncoder(&buf).Encode(matter.Params); err != nil {
+				return "", fmt.Errorf("failed to marshal frontmatter to TOML: %w", err)
+			}
+		}
+		return fmt.Sprintf("+++\n%s+++\n\n", buf.String()), nil
+	case "json":
+		b, err := marshalJSONMatter(matter)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal frontmatter to JSON: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", "  "); err != nil {
+			return "", fmt.Errorf("failed to marshal frontmatter to JSON: %w", err)
+		}
+		return fmt.Sprintf("%s\n\n", buf.String()), nil
+	}
+	return "", fmt.Errorf("unknown frontmatter format %q", format)
+}
+
+// Encodes frontmatter as a JSON object, its fields in order followed by its
+// params. encoding/json writes unset dates as "" because omitempty doesn't
+// apply to structs, so they and unset lists are left out here.
+func marshalJSONMatter(matter Matter) ([]byte, error) {
+	var buf bytes.Buffer
+	add := func(key string, value any) error {
+		v, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		k, _ := json.Marshal(key)
+		if buf.Len() == 0 {
+			buf.WriteByte('{')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+		return nil
+	}
+	v := reflect.ValueOf(matter)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		value := v.Field(i)
+		_, isDate := value.Interface().(YAMLDate)
+		if value.IsZero() && (opts == "omitempty" || isDate || value.Kind() == reflect.Slice) {
+			continue
+		}
+		if err := add(name, value.Interface()); err != nil {
+			return nil, err
+		}
+	}
+	keys := make([]string, 0, len(matter.Params))
+	for key := range matter.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := add(key, matter.Params[key]); err != nil {
+			return nil, err
+		}
+	}
+	if buf.Len() == 0 {
+		buf.WriteByte('{')
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Returns the format of the frontmatter at the start of content, or an empty
+// string if it has none
+func frontmatterFormat(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte("---")):
+		return "yaml"
+	case bytes.HasPrefix(content, []byte("+++")):
+		return "toml"
+	case bytes.HasPrefix(content, []byte("{")):
+		return "json"
+	}
+	return ""
+}
+
+// Rewrites the frontmatter of content in the given format, so that
+// archetypes written in one format suit sites using another. Content already
+// in the format is left as it is, comments and all.
+func convertFrontmatter(content []byte, format string) ([]byte, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		format = "yaml"
+	}
+	from := frontmatterFormat(content)
+	if from == "" || from == format {
+		return content, nil
+	}
+	matter, body, err := parseMatter(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse archetype frontmatter: %w", err)
+	}
+	frontmatter, err := marshalMatter(matter, format)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(frontmatter), bytes.TrimLeft(body, "\n")...), nil
+}
+
+// Formats tags as a comma separated list of quoted strings, for use in
+// archetypes
 func formatTags(tags []string) string {
 	if len(tags) == 0 {
 		return ""
 	}
+	quoted := make([]string, len(tags))
 	for i, tag := range tags {
-		tags[i] = fmt.Sprintf("\"%s\"", tag)
+		quoted[i] = fmt.Sprintf("%q", tag)
 	}
-	return strings.Join(tags, ", ")
+	return strings.Join(quoted, ", ")
 }
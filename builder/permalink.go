@@ -0,0 +1,52 @@
+package builder
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// Permalink styles for posts
+const (
+	// posts/my-post.html
+	PermalinkSlug = "slug"
+	// posts/2024/05/01/my-post.html
+	PermalinkDated = "dated"
+	// posts/my-post/
+	PermalinkPretty = "pretty"
+)
+
+var PermalinkStyles = []string{PermalinkSlug, PermalinkDated, PermalinkPretty}
+
+func getPostOutputFilePath(config config.Config, outputDir string, md MarkdownData) string {
+	outputDirPath := filepath.Join(outputDir, md.section)
+	slug := md.Frontmatter.Slug.String()
+	switch config.Site.Permalinks {
+	case PermalinkDated:
+		outputDirPath = filepath.Join(outputDirPath, filepath.FromSlash(md.Frontmatter.Date.Format("2006/01/02")))
+	case PermalinkPretty:
+		return filepath.Join(outputDirPath, slug, "index"+config.Files.Extension)
+	}
+	// Page bundles get a directory of their own for their resources
+	if md.bundleDir != "" {
+		return filepath.Join(outputDirPath, slug, "index"+config.Files.Extension)
+	}
+	return filepath.Join(outputDirPath, slug+config.Files.Extension)
+}
+
+// Returns the site root relative URL path of an output file, leaving off the
+// file name of index files
+func getURLPath(config config.Config, outputFilePath string) (string, error) {
+	rel, err := filepath.Rel(config.Directories.Dist, outputFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate relative path for output file: %w", err)
+	}
+	urlPath := "/" + filepath.ToSlash(rel)
+	if path.Base(urlPath) == "index"+config.Files.Extension {
+		urlPath = strings.TrimSuffix(urlPath, "index"+config.Files.Extension)
+	}
+	return urlPath, nil
+}
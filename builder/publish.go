@@ -0,0 +1,63 @@
+package builder
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// BuildOptions control which content is included in a build
+type BuildOptions struct {
+	// Include content dated after Now
+	Future bool
+	// Include content marked as a draft
+	Drafts bool
+	// Minify the HTML and XML written by the build
+	Minify bool
+	// The time used to decide what is published. Defaults to the current time.
+	Now time.Time
+}
+
+func (o BuildOptions) now() time.Time {
+	if o.Now.IsZero() {
+		return time.Now()
+	}
+	return o.Now
+}
+
+// Parses a date given on the command line, using the same layouts and site
+// timezone as frontmatter dates
+func ParseDate(cfg config.Config, s string) (time.Time, error) {
+	if err := setSiteLocation(cfg); err != nil {
+		return time.Time{}, err
+	}
+	return parseDate(s)
+}
+
+func isScheduled(matter Matter, now time.Time) bool {
+	return time.Time(matter.Date).After(now)
+}
+
+func isExpired(matter Matter, now time.Time) bool {
+	return !matter.ExpiryDate.IsZero() && !time.Time(matter.ExpiryDate).After(now)
+}
+
+// Removes drafts and content that is scheduled for the future or has expired
+func filterPublished(mds []MarkdownData, opts BuildOptions) []MarkdownData {
+	now := opts.now()
+	published := mds[:0]
+	for _, md := range mds {
+		switch {
+		case !opts.Drafts && md.Frontmatter.IsDraft:
+			fmt.Printf("Skipping %q, it is a draft\n", md.Frontmatter.Title)
+		case !opts.Future && isScheduled(md.Frontmatter, now):
+			fmt.Printf("Skipping %q, scheduled for %s\n", md.Frontmatter.Title, md.Frontmatter.Date.Format(time.RFC3339))
+		case isExpired(md.Frontmatter, now):
+			fmt.Printf("Skipping %q, expired on %s\n", md.Frontmatter.Title, md.Frontmatter.ExpiryDate.Format(time.RFC3339))
+		default:
+			published = append(published, md)
+		}
+	}
+	return published
+}
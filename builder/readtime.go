@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"html"
+	"regexp"
+	"unicode"
+)
+
+const defaultWordsPerMinute = 200
+
+var htmlTagPattern = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// Strips tags from rendered HTML, leaving the readable text
+func plainText(s string) string {
+	return html.UnescapeString(htmlTagPattern.ReplaceAllString(s, " "))
+}
+
+// CJK scripts don't separate words with spaces, so each character is counted as a word
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+func countWords(text string) int {
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			count++
+			inWord = false
+		case unicode.IsSpace(r) || (unicode.IsPunct(r) && r != '\'' && r != '-'):
+			inWord = false
+		case !inWord:
+			count++
+			inWord = true
+		}
+	}
+	return count
+}
+
+// Returns the estimated reading time in whole minutes, rounding up
+func readingTime(words int, wordsPerMinute int) int {
+	if words == 0 {
+		return 0
+	}
+	if wordsPerMinute <= 0 {
+		wordsPerMinute = defaultWordsPerMinute
+	}
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
+func addReadingStats(mds []MarkdownData, wordsPerMinute int) {
+	for i := range mds {
+		mds[i].WordCount = countWords(plainText(string(mds[i].HTMLContent)))
+		mds[i].ReadingTime = readingTime(mds[i].WordCount, wordsPerMinute)
+	}
+}
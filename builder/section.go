@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// Reports whether dir is one of the site's own directories, such as static,
+// rather than a directory of content
+func isReservedDir(cfg config.Config, dir string) bool {
+	reserved := []string{
+		cfg.Directories.Posts,
+		cfg.Directories.Pages,
+		cfg.Directories.Static,
+		cfg.Directories.Templates,
+		cfg.Directories.Dist,
+		getArchetypesDir(cfg),
+		getI18nDir(cfg),
+		getDataDir(cfg),
+		getThemesDir(cfg),
+		getCacheDir(cfg),
+	}
+	for _, lang := range cfg.Languages {
+		reserved = append(reserved, lang.ContentDir)
+	}
+	for _, r := range reserved {
+		if r != "" && filepath.Clean(r) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the directory holding the posts directory and any other sections,
+// e.g. content. It is only a content directory if it is a subdirectory of
+// the project, as posts kept at the top of the project have other
+// directories such as scripts next to them.
+func getSectionsDir(cfg config.Config) (string, bool) {
+	contentDir := filepath.Dir(cfg.Directories.Posts)
+	rel, err := filepath.Rel(cfg.Root, contentDir)
+	return contentDir, err == nil && rel != "." && filepath.IsLocal(rel)
+}
+
+// Returns the directories of sections other than posts and pages, those next
+// to the posts directory that lumaca new creates for other kinds of content,
+// e.g. content/talk. They are built like posts.
+func customSections(cfg config.Config) ([]string, error) {
+	contentDir, ok := getSectionsDir(cfg)
+	if !ok {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(contentDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content directory: %w", err)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		dir := filepath.Join(contentDir, entry.Name())
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || isReservedDir(cfg, dir) {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
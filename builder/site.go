@@ -0,0 +1,57 @@
+package builder
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+// A value of a taxonomy, such as a tag, and the content that has it
+type Term struct {
+	Name  string
+	Slug  string
+	Pages []MarkdownData
+}
+
+// Groups content by each of its taxonomies. Only tags are supported, keyed
+// by "tags". Terms are sorted by name and the content keeps its order.
+func buildTaxonomies(mds []MarkdownData) map[string][]Term {
+	var terms []Term
+	index := make(map[string]int)
+	for _, md := range mds {
+		for _, tag := range md.Frontmatter.Tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+			// Tags differing only in case or punctuation are the same term
+			s := slug.Make(tag)
+			i, ok := index[s]
+			if !ok {
+				i = len(terms)
+				index[s] = i
+				terms = append(terms, Term{Name: tag, Slug: s})
+			}
+			terms[i].Pages = append(terms[i].Pages, md)
+		}
+	}
+	sort.SliceStable(terms, func(i, j int) bool {
+		return strings.ToLower(terms[i].Name) < strings.ToLower(terms[j].Name)
+	})
+	return map[string][]Term{"tags": terms}
+}
+
+// Links each post to its neighbours. Posts are sorted newest first, so the
+// previous post is the older one after it and the next is the newer one
+// before it.
+func linkSection(mds []MarkdownData) {
+	for i := range mds {
+		if i > 0 {
+			mds[i].NextInSection = &mds[i-1]
+		}
+		if i < len(mds)-1 {
+			mds[i].PrevInSection = &mds[i+1]
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+// The theme built into Lumaca, used unless the project has its own copy in
+// its themes directory
+const defaultTheme = "default"
+
+// The directories of a theme, each of which a project can override file by
+// file with its own directory
+const (
+	themeTemplates  = "templates"
+	themeStatic     = "static"
+	themeArchetypes = "archetypes"
+	themeI18n       = "i18n"
+)
+
+// Where the default theme's directories are in EmbeddedFiles
+var embeddedThemeDirs = map[string]string{
+	themeTemplates:  "templates",
+	themeStatic:     "content/static",
+	themeArchetypes: "archetypes",
+	themeI18n:       "i18n",
+}
+
+func getThemesDir(cfg config.Config) string {
+	if cfg.Directories.Themes == "" {
+		return filepath.Join(cfg.Root, "themes")
+	}
+	return cfg.Directories.Themes
+}
+
+// Returns the directory of a theme in the project, if it has one
+func localThemeDir(cfg config.Config, name string) (string, bool) {
+	dir := filepath.Join(getThemesDir(cfg), name)
+	info, err := os.Stat(dir)
+	return dir, err == nil && info.IsDir()
+}
+
+// Checks that the site's theme can be found
+func checkTheme(cfg config.Config) error {
+	name := cfg.Site.Theme
+	if name == "" || name == defaultTheme {
+		return nil
+	}
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("theme %q must be the name of a directory in %s", name, getThemesDir(cfg))
+	}
+	if _, ok := localThemeDir(cfg, name); !ok {
+		return fmt.Errorf("theme %q not found in %s", name, getThemesDir(cfg))
+	}
+	return nil
+}
+
+// Returns one of the directories of the site's theme, or nil if the site
+// doesn't use a theme
+func themeDir(cfg config.Config, dir string) (fs.FS, error) {
+	if cfg.Site.Theme == "" {
+		return nil, nil
+	}
+	if err := checkTheme(cfg); err != nil {
+		return nil, err
+	}
+	if local, ok := localThemeDir(cfg, cfg.Site.Theme); ok {
+		return os.DirFS(filepath.Join(local, dir)), nil
+	}
+	return fs.Sub(EmbeddedFiles, embeddedThemeDirs[dir])
+}
+
+// Returns the files of one of the theme's directories with the project's
+// own directory layered over them, so that a project file replaces the
+// theme's file with the same path
+func layeredDir(cfg config.Config, dir string, projectDir string) (fs.FS, error) {
+	theme, err := themeDir(cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+	var layers layeredFS
+	if projectDir != "" {
+		layers = append(layers, os.DirFS(projectDir))
+	}
+	if theme != nil {
+		layers = append(layers, theme)
+	}
+	return layers, nil
+}
+
+// A file system made of layers, where a file in a layer hides the files
+// with the same path in the layers after it. Directories are merged.
+type layeredFS []fs.FS
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for _, fsys := range l {
+		f, err := fsys.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return f, err
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (l layeredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	seen := make(map[string]bool)
+	found := false
+	for _, fsys := range l {
+		layer, err := fs.ReadDir(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, entry := range layer {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// Copies every file in fsys to the directory dst
+func copyFS(fsys fs.FS, dst string) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == "." {
+			// Nothing to copy
+			return fs.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, filepath.FromSlash(path))
+		if d.IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			return nil
+		}
+		return copyFSFile(fsys, path, target)
+	})
+}
+
+func copyFSFile(fsys fs.FS, path string, dst string) error {
+	source, err := fsys.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer source.Close()
+	destination, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create dst file: %w", err)
+	}
+	_, err = io.Copy(destination, source)
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package builder
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jmcharter/lumaca/config"
+)
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// The directories and files a build reads from. The output directory is
+// left out so that writing the build doesn't trigger another one.
+func watchedPaths(cfg config.Config) []string {
+	paths := []string{
+		// Holds the home page content and other sections
+		filepath.Dir(cfg.Directories.Posts),
+		cfg.Directories.Posts,
+		cfg.Directories.Pages,
+		cfg.Directories.Static,
+		cfg.Directories.Templates,
+		getI18nDir(cfg),
+		getDataDir(cfg),
+	}
+	if cfg.Site.Theme != "" {
+		if dir, ok := localThemeDir(cfg, cfg.Site.Theme); ok {
+			paths = append(paths, dir)
+		}
+	}
+	for _, lang := range cfg.Languages {
+		if lang.ContentDir != "" {
+			paths = append(paths, lang.ContentDir)
+		}
+	}
+	// config.toml and any environment overlays
+	configs, _ := filepath.Glob(filepath.Join(cfg.Root, "config*.toml"))
+	return append(paths, configs...)
+}
+
+// Records the modification time and size of every file under the given paths
+func snapshot(paths []string) map[string]fileState {
+	state := make(map[string]fileState)
+	for _, root := range paths {
+		if root == "" {
+			continue
+		}
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				// Missing paths are skipped, they may be created later
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			state[path] = fileState{info.ModTime(), info.Size()}
+			return nil
+		})
+	}
+	return state
+}
+
+func changed(before, after map[string]fileState) bool {
+	if len(before) != len(after) {
+		return true
+	}
+	for path, state := range after {
+		if before[path] != state {
+			return true
+		}
+	}
+	return false
+}
+
+func rebuild(cfg config.Config, opts BuildOptions) {
+	start := time.Now()
+	if err := run(cfg, opts); err != nil {
+		fmt.Fprintln(os.Stderr, "Build failed:", err)
+		return
+	}
+	fmt.Printf("Build finished in %s.\n", time.Since(start).Round(time.Millisecond))
+}
+
+// Builds the site, then polls its source files and rebuilds whenever one is
+// added, changed or removed. The config is reloaded with load before each
+// rebuild so that changes to it take effect. A failed build is reported
+// without stopping the watch.
+func Watch(load func() (config.Config, error), opts BuildOptions, interval time.Duration) error {
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Build starting...")
+	rebuild(cfg, opts)
+	state := snapshot(watchedPaths(cfg))
+	fmt.Println("Watching for changes, press Ctrl+C to stop.")
+	for {
+		time.Sleep(interval)
+		next := snapshot(watchedPaths(cfg))
+		if !changed(state, next) {
+			continue
+		}
+		state = next
+		fmt.Println("Change detected, rebuilding...")
+		reloaded, err := load()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to reload config, using the previous one:", err)
+		} else {
+			cfg = reloaded
+		}
+		rebuild(cfg, opts)
+		// The config may now point at different directories
+		state = snapshot(watchedPaths(cfg))
+	}
+}
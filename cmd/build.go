@@ -1,22 +1,61 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/jmcharter/lumaca/builder"
+	"github.com/jmcharter/lumaca/config"
 	"github.com/spf13/cobra"
 )
 
+var buildFuture bool
+var buildDrafts bool
+var buildMinify bool
+var buildNow string
+var buildWatch bool
+var buildPoll time.Duration
+
 // buildCmd represents the build command
 var buildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Builds and compiles the site's source files into static files ready for deployment.",
 	Long:  `Compiles all source content, templates, and static assets into a complete set of optimized, static HTML and CSS and renders an RSS feed.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		builder.Build(cfg)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := reportIssues(builder.CheckConfig(cfg)); err != nil {
+			return err
+		}
+		opts := builder.BuildOptions{
+			Future: buildFuture || cfg.Build.Future,
+			Drafts: buildDrafts || cfg.Build.Drafts,
+			Minify: buildMinify || cfg.Build.Minify,
+		}
+		if buildNow != "" {
+			now, err := builder.ParseDate(cfg, buildNow)
+			if err != nil {
+				return err
+			}
+			opts.Now = now
+		}
+		if buildWatch {
+			return builder.Watch(func() (config.Config, error) {
+				if err := initConfig(); err != nil {
+					return config.Config{}, err
+				}
+				return cfg, nil
+			}, opts, buildPoll)
+		}
+		builder.Build(cfg, opts)
+		return nil
 	},
 }
 
 func init() {
-	buildCmd.Flags().Bool("watch", false, "NOT CURRENTLY IMPLEMENTED - Continuously watch source files for changes and rebuild automitically when changes are detected.")
+	buildCmd.Flags().BoolVarP(&buildWatch, "watch", "w", false, "Continuously watch source files for changes and rebuild automatically when changes are detected")
+	buildCmd.Flags().DurationVar(&buildPoll, "poll", 500*time.Millisecond, "How often to check for changes when watching")
+	buildCmd.Flags().BoolVar(&buildFuture, "future", false, "Include content with a date in the future")
+	buildCmd.Flags().BoolVar(&buildDrafts, "drafts", false, "Include draft content, even if build.drafts is false")
+	buildCmd.Flags().BoolVar(&buildMinify, "minify", false, "Minify the HTML and XML output")
+	buildCmd.Flags().StringVar(&buildNow, "now", "", "Build as if the current time were the given date, e.g. 2024-05-01 or 2024-05-01T09:00:00Z")
 
 	// Here you will define your flags and configuration settings.
 
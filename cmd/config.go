@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/jmcharter/lumaca/builder"
+	"github.com/jmcharter/lumaca/config"
+	"github.com/spf13/cobra"
+)
+
+// configCmd groups commands for inspecting the config file
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the site's config file",
+}
+
+var configCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the config file for errors",
+	Long:  `Checks config.toml for unknown keys, missing directories and invalid values, reporting each problem found. Exits with an error if any are serious enough to stop a build.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issues := builder.CheckConfig(cfg)
+		if len(issues) == 0 {
+			fmt.Println("No problems found in", cfgPath)
+			return nil
+		}
+		return reportIssues(issues)
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective config",
+	Long:  `Prints the config as it will be used, after merging any environment overlay and applying LUMACA_ environment variable overrides. Directories are shown resolved against the project root.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cfg.Environment != "" {
+			fmt.Printf("# Environment: %s\n", cfg.Environment)
+		}
+		return toml.NewEncoder(os.Stdout).Encode(cfg)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configCheckCmd)
+	configCmd.AddCommand(configShowCmd)
+}
+
+// Prints issues to stderr, returning an error if any of them are errors
+func reportIssues(issues []config.Issue) error {
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+	}
+	if config.HasErrors(issues) {
+		return errors.New("invalid config, see above")
+	}
+	return nil
+}
@@ -1,24 +1,81 @@
 package cmd
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
 	"github.com/jmcharter/lumaca/builder"
 	"github.com/spf13/cobra"
 )
 
-var cfgAuthor string
-var cfgTitle string
+var initOpts builder.InitOptions
+var initYes bool
 
 // initCmd represents the init command
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize a new lumaca project",
-	Long:  `Initialize a new lumaca project, creating the default directory structure and guiding you through the creation of a config file if one is not already detected.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		builder.Initialise(cfgAuthor, cfgTitle)
+	Long: `Initialize a new lumaca project, creating the default directory structure and guiding you through the creation of a config file if one is not already detected.
+
+When run in a terminal, init asks for each setting in turn, suggesting any value given by flags. Use --yes to accept the flags and defaults without prompting.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if initFileExists() {
+			fmt.Println("Project already initialised")
+			return nil
+		}
+		opts := initOpts.WithDefaults()
+		if !initYes && isTerminal(os.Stdin) {
+			opts = runInitWizard(bufio.NewReader(os.Stdin), os.Stdout, opts)
+		}
+		return builder.Initialise(opts)
 	},
 }
 
 func init() {
-	initCmd.Flags().StringVarP(&cfgAuthor, "author", "a", "", "Name of the primary blog author (optional)")
-	initCmd.Flags().StringVarP(&cfgTitle, "title", "t", "", "Title of the blog (optional)")
+	initCmd.Flags().StringVarP(&initOpts.Author, "author", "a", "", "Name of the primary blog author (optional)")
+	initCmd.Flags().StringVarP(&initOpts.Title, "title", "t", "", "Title of the blog (optional)")
+	initCmd.Flags().StringVar(&initOpts.BaseURL, "base-url", "", "URL the site will be published at, e.g. https://example.com/ (optional)")
+	initCmd.Flags().StringVar(&initOpts.Language, "language", "", "Language code of the site's content, e.g. en-gb (optional)")
+	initCmd.Flags().StringVar(&initOpts.Permalinks, "permalinks", "", "Permalink style for posts: "+strings.Join(builder.PermalinkStyles, ", ")+" (optional)")
+	initCmd.Flags().StringVar(&initOpts.Theme, "theme", "", "Starter theme to install: "+strings.Join(builder.StarterThemes, ", ")+" (optional)")
+	initCmd.Flags().BoolVarP(&initYes, "yes", "y", false, "Don't prompt, use the flags and defaults")
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func runInitWizard(in *bufio.Reader, out io.Writer, opts builder.InitOptions) builder.InitOptions {
+	opts.Title = prompt(in, out, "Site title", opts.Title, builder.ValidateTitle)
+	opts.Author = prompt(in, out, "Author", opts.Author, nil)
+	opts.BaseURL = prompt(in, out, "Base URL", opts.BaseURL, builder.ValidateBaseURL)
+	opts.Language = prompt(in, out, "Language", opts.Language, builder.ValidateLanguage)
+	opts.Permalinks = prompt(in, out, "Permalink style ("+strings.Join(builder.PermalinkStyles, ", ")+")", opts.Permalinks, builder.ValidatePermalinks)
+	opts.Theme = prompt(in, out, "Starter theme ("+strings.Join(builder.StarterThemes, ", ")+")", opts.Theme, builder.ValidateTheme)
+	return opts
+}
+
+// Asks for a value until a valid one is given. An empty answer accepts the
+// default, as does the end of input.
+func prompt(in *bufio.Reader, out io.Writer, question string, def string, validate func(string) error) string {
+	for {
+		fmt.Fprintf(out, "%s [%s]: ", question, def)
+		line, err := in.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if validate == nil {
+			return answer
+		}
+		verr := validate(answer)
+		if verr == nil || err != nil {
+			return answer
+		}
+		fmt.Fprintln(out, verr)
+	}
 }
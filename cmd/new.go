@@ -1,29 +1,69 @@
 package cmd
 
 import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
 	"github.com/jmcharter/lumaca/builder"
 	"github.com/spf13/cobra"
 )
 
-var title string
-var author string
-var draft bool
+var newOpts builder.NewOptions
+var openEditor bool
 
 var newCmd = &cobra.Command{
-	Use:   "new",
+	Use:   "new [post|page|<kind>]",
 	Short: "Create a new page with basic metadata included.",
-	Long:  `Create a new file representing a page or blog post. Metadata will be added to the top, populating information from system and config data.`,
+	Long: `Create a new file representing a page or blog post. Metadata will be added to the top, populating information from system and config data.
+
+The kind of content defaults to post. Its initial frontmatter and body come from the matching archetype in the archetypes directory (e.g. archetypes/page.md), or archetypes/default.md if there is none.
+
+Existing files are never overwritten unless --force is given.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		builder.New(cfg, title, author, draft)
+		if len(args) > 0 {
+			newOpts.Kind = args[0]
+		}
+		path, err := builder.New(cfg, newOpts)
+		if err != nil {
+			return err
+		}
+		if openEditor {
+			return editFile(path)
+		}
 		return nil
 	},
 }
 
 func init() {
 
-	newCmd.Flags().StringVarP(&title, "title", "t", "", "Title for the new page (required)")
-	newCmd.Flags().StringVarP(&author, "author", "a", "", "Author of the new page (optional)")
-	newCmd.Flags().BoolVarP(&draft, "draft", "d", false, "Whether the new post is a draft. Defaults to false. (optional)")
+	newCmd.Flags().StringVarP(&newOpts.Title, "title", "t", "", "Title for the new page (required)")
+	newCmd.Flags().StringVarP(&newOpts.Author, "author", "a", "", "Author of the new page (optional)")
+	newCmd.Flags().BoolVarP(&newOpts.Draft, "draft", "d", false, "Whether the new post is a draft. Defaults to false. (optional)")
+	newCmd.Flags().StringSliceVar(&newOpts.Tags, "tags", nil, "Comma separated tags for the new page (optional)")
+	newCmd.Flags().StringVar(&newOpts.Date, "date", "", "Date for the new page, e.g. 2024-05-01 or 2024-05-01T09:30:00Z. Defaults to now. (optional)")
+	newCmd.Flags().StringVarP(&newOpts.Path, "path", "p", "", "File or directory to create the new page in, instead of the default for its kind (optional)")
+	newCmd.Flags().BoolVarP(&newOpts.Force, "force", "f", false, "Overwrite the file if it already exists")
+	newCmd.Flags().BoolVarP(&openEditor, "edit", "e", false, "Open the new file in $VISUAL or $EDITOR once created")
 
 	newCmd.MarkFlagRequired("title")
 }
+
+func editFile(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	// Editors are often configured with arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	if len(fields) == 0 {
+		return errors.New("no editor configured, set $VISUAL or $EDITOR")
+	}
+	c := exec.Command(fields[0], append(fields[1:], path)...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
@@ -1,9 +1,7 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
-	"log"
 	"os"
 
 	"github.com/jmcharter/lumaca/config"
@@ -11,36 +9,45 @@ import (
 )
 
 var cfg config.Config
-var initialised bool = false
+var cfgFile string
+
+// The path of the loaded config file
+var cfgPath string
+var sourceDir string
+var environment string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "lumaca",
 	Short: "Lumaca, the simple static site generator.",
 	Long:  `Lumaca is a fast and simple static site generator that transforms your markdown files into a static website.`,
+	// Errors are printed by Execute, and usage is only useful for flag errors
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if sourceDir != "" && cmd == initCmd {
+			// init creates the project in the source directory
+			if err := os.MkdirAll(sourceDir, 0755); err != nil {
+				return fmt.Errorf("failed to create source directory: %w", err)
+			}
+			return os.Chdir(sourceDir)
+		}
+		if cmd == initCmd || !cmd.Runnable() {
+			return nil
+		}
+		return initConfig()
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
-//
-//	func Execute() {
-//		if initialised {
-//			err := rootCmd.Execute()
-//			if err != nil {
-//				os.Exit(1)
-//			}
-//		}
-//	}
 func Execute() {
-	if !initFileExists() {
-		// Only register init command if init file does not exist
-		rootCmd.AddCommand(initCmd)
-	} else {
-		rootCmd.AddCommand(initCmd)
-		rootCmd.AddCommand(newCmd)
-		rootCmd.AddCommand(buildCmd)
-		rootCmd.AddCommand(serveCmd)
-	}
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(newCmd)
+	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(themeCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -48,27 +55,33 @@ func Execute() {
 	}
 }
 
-func initFileExists() bool {
-	_, err := os.Stat("config.toml")
-	return !os.IsNotExist(err)
-}
 func init() {
-	// Try to initialize configuration, but allow for it to not exist
-	initConfig()
+	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "Path to the config file (default: config.toml in the source directory or the nearest parent containing one)")
+	rootCmd.PersistentFlags().StringVarP(&sourceDir, "source", "s", "", "Project directory to search for the config file from (default: the current directory)")
+	rootCmd.PersistentFlags().StringVar(&environment, "environment", os.Getenv("LUMACA_ENV"), "Environment to build for, merging config.<environment>.toml onto config.toml (default: $LUMACA_ENV)")
+}
 
+func initFileExists() bool {
+	_, err := os.Stat(config.FileName)
+	return !os.IsNotExist(err)
 }
 
-func initConfig() {
-	var err error
-	cfg, err = config.InitConfig()
-	if err != nil {
-		if errors.Is(err, config.DecodeFileError) {
-			// Configuration file does not exist, log an info message and continue
-			log.Println("Configuration file not found, please run init")
-		} else {
-			// Some other error occurred during config initialization
-			log.Fatal(err)
+// Loads the config given by --config, or finds it from the source directory
+func initConfig() error {
+	path := cfgFile
+	if path == "" {
+		dir := sourceDir
+		if dir == "" {
+			dir = "."
+		}
+		var err error
+		path, err = config.Find(dir)
+		if err != nil {
+			return fmt.Errorf("%w, please run init", err)
 		}
 	}
-	initialised = true
+	var err error
+	cfg, err = config.Load(path, environment)
+	cfgPath = path
+	return err
 }
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jmcharter/lumaca/builder"
+	"github.com/spf13/cobra"
+)
+
+var themeInstallName string
+var themeForce bool
+
+// themeCmd groups commands for managing the site's themes
+var themeCmd = &cobra.Command{
+	Use:   "theme",
+	Short: "List, install and customise themes",
+}
+
+var themeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available themes",
+	Long:  `Lists the built in theme and the themes installed in the project's themes directory, marking the one the site uses.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		themes, err := builder.ListThemes(cfg)
+		if err != nil {
+			return err
+		}
+		for _, theme := range themes {
+			marker := " "
+			if theme.Active {
+				marker = "*"
+			}
+			location := theme.Dir
+			if location == "" {
+				location = "(built in)"
+			}
+			fmt.Printf("%s %-20s %s\n", marker, theme.Name, location)
+		}
+		return nil
+	},
+}
+
+var themeInstallCmd = &cobra.Command{
+	Use:   "install <dir|archive>",
+	Short: "Install a theme from a directory or archive",
+	Long: `Installs a theme into the project's themes directory from a directory, or a .zip, .tar, .tar.gz or .tgz archive. An archive holding a single directory, as downloaded from most code hosts, is unwrapped. No network access is needed.
+
+The theme is named after the directory or archive unless --name is given. Set theme in the [site] section of config.toml to use it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := builder.InstallTheme(cfg, args[0], themeInstallName, themeForce)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Theme installed to", dir)
+		return nil
+	},
+}
+
+var themeEjectCmd = &cobra.Command{
+	Use:   "eject <file>",
+	Short: "Copy a file from the theme into the project to customise it",
+	Long: `Copies a file or directory from the site's theme into the project, where it replaces the theme's version, e.g.
+
+  lumaca theme eject templates/post.html
+  lumaca theme eject static/css/lumaca.css
+  lumaca theme eject archetypes
+
+Paths are relative to the theme, and those not starting with templates, static, archetypes or i18n are taken to be templates.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		written, err := builder.EjectThemeFile(cfg, args[0], themeForce)
+		for _, path := range written {
+			fmt.Println("Copied", path)
+		}
+		return err
+	},
+}
+
+func init() {
+	themeInstallCmd.Flags().StringVarP(&themeInstallName, "name", "n", "", "Name to install the theme under (default: the name of the directory or archive)")
+	themeInstallCmd.Flags().BoolVarP(&themeForce, "force", "f", false, "Replace a theme that is already installed")
+	themeEjectCmd.Flags().BoolVarP(&themeForce, "force", "f", false, "Replace files the project already has")
+
+	themeCmd.AddCommand(themeListCmd)
+	themeCmd.AddCommand(themeInstallCmd)
+	themeCmd.AddCommand(themeEjectCmd)
+}
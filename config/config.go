@@ -2,36 +2,187 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 
 	"github.com/BurntSushi/toml"
 )
 
+// The name of the config file at the root of a project
+const FileName = "config.toml"
+
 var DecodeFileError = errors.New("failed to decode config file")
+var NotFoundError = errors.New("config file not found")
 
 type Config struct {
 	Directories struct {
-		Posts     string
-		Pages     string
-		Static    string
-		Templates string
-		Dist      string
+		Posts      string
+		Pages      string
+		Static     string
+		Templates  string
+		Archetypes string
+		I18n       string
+		Data       string
+		Themes     string
+		// Where processed images are kept between builds
+		Cache string
+		Dist  string
 	}
 	Author struct {
 		Name string
 	}
 	Files struct {
-		Extension string
+		Extension   string
+		Frontmatter string
 	}
 	Site struct {
-		Title  string
-		Author string
+		Title          string
+		Author         string
+		BaseURL        string `toml:"base_url"`
+		Language       string
+		Permalinks     string
+		DateFormat     string `toml:"date_format"`
+		WordsPerMinute int    `toml:"words_per_minute"`
+		Timezone       string
+		// The theme providing templates, static files and archetypes
+		// that the project's own files are layered over
+		Theme string
+	}
+	Images struct {
+		// Widths in pixels to resize images in content to. Images are
+		// only processed when this is set.
+		Widths []int
+		// JPEG quality from 1 to 100
+		Quality int
+		// The sizes attribute of processed images
+		Sizes string
+		// Format to convert images to, jpeg or png. Defaults to the
+		// format of each image.
+		Format string
+	}
+	Build struct {
+		// Include draft content. True if the config file doesn't set it.
+		Drafts bool
+		// Include content dated in the future
+		Future bool
+		// Minify the HTML and XML output
+		Minify bool
+	}
+	// Languages the site is published in, keyed by language code. The
+	// language given by site.language is the default.
+	Languages map[string]Language
+	// Navigation menus keyed by name, e.g. [[menus.main]]
+	Menus  map[string][]MenuEntry
+	Params map[string]any
+	// The environment the config was loaded for, if any
+	Environment string `toml:"-"`
+	// The directory containing the config file, which relative directories
+	// are resolved against
+	Root string `toml:"-"`
+	// Keys in the config file that don't match any setting
+	undecoded []string
+}
+
+// Language is a language the site's content is published in
+type Language struct {
+	// Display name, e.g. Français
+	Name string
+	// Site title in this language, if it differs from site.title
+	Title  string
+	Weight int
+	// Directory holding this language's content, with the same layout as
+	// the content directory, e.g. content/fr/posts. Optional, as content can
+	// also be marked with a language suffix, e.g. my-post.fr.md
+	ContentDir string `toml:"content_dir"`
+}
+
+// MenuEntry is a link in a navigation menu
+type MenuEntry struct {
+	Name string
+	// A path on the site, e.g. /pages/about.html, or an external URL.
+	// Optional for entries that only hold others.
+	URL string `toml:"url"`
+	// Entries are sorted by weight, lightest first, then by name
+	Weight int
+	// Names the entry for others to give as their parent. Defaults to the
+	// entry's name.
+	Identifier string
+	// The identifier of the entry this one is nested under
+	Parent string
+}
+
+// Searches dir and then each of its parents for a config file, returning the
+// path of the first one found
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%w: no %s in this directory or any parent", NotFoundError, FileName)
+		}
+		dir = parent
 	}
 }
 
-func InitConfig() (Config, error) {
+// Loads the config file at path, resolving its directories relative to the
+// directory it is in. If env is given, the environment's overlay file (e.g.
+// config.production.toml) is merged on top. Environment variables are applied
+// last.
+func Load(path string, env string) (Config, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return Config{}, fmt.Errorf("%w: %s", NotFoundError, path)
+	}
 	var cfg Config
-	if _, err := toml.DecodeFile("config.toml", &cfg); err != nil {
-		return Config{}, DecodeFileError
+	// Drafts were published before build.drafts existed, so configs that
+	// don't set it keep publishing them. New projects set it to false.
+	cfg.Build.Drafts = true
+	meta, err := toml.DecodeFile(path, &cfg)
+	if err != nil {
+		return Config{}, decodeError(path, err)
+	}
+	for _, key := range meta.Undecoded() {
+		cfg.undecoded = append(cfg.undecoded, key.String())
+	}
+	if env != "" {
+		if err := cfg.applyOverlay(path, env); err != nil {
+			return Config{}, err
+		}
 	}
+	if err := cfg.applyEnv(os.Environ()); err != nil {
+		return Config{}, err
+	}
+	cfg.Environment = env
+	cfg.Root = filepath.Dir(path)
+	cfg.resolveDirectories()
 	return cfg, nil
 }
+
+func (c *Config) resolveDirectories() {
+	dirs := reflect.ValueOf(&c.Directories).Elem()
+	for i := 0; i < dirs.NumField(); i++ {
+		field := dirs.Field(i)
+		if field.Kind() != reflect.String || field.String() == "" || filepath.IsAbs(field.String()) {
+			continue
+		}
+		field.SetString(filepath.Join(c.Root, field.String()))
+	}
+	for code, lang := range c.Languages {
+		if lang.ContentDir != "" && !filepath.IsAbs(lang.ContentDir) {
+			lang.ContentDir = filepath.Join(c.Root, lang.ContentDir)
+			c.Languages[code] = lang
+		}
+	}
+}
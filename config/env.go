@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Prefix of environment variables that override config settings
+const envPrefix = "LUMACA_"
+
+// Returns the path of the overlay for an environment, e.g. config.staging.toml
+// for config.toml
+func overlayPath(path string, env string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
+
+// Decodes the environment's overlay, if it has one, on top of the config
+func (c *Config) applyOverlay(path string, env string) error {
+	overlay := overlayPath(path, env)
+	if _, err := os.Stat(overlay); err != nil {
+		return nil
+	}
+	meta, err := toml.DecodeFile(overlay, c)
+	if err != nil {
+		return decodeError(overlay, err)
+	}
+	for _, key := range meta.Undecoded() {
+		c.undecoded = append(c.undecoded, fmt.Sprintf("%s (in %s)", key, filepath.Base(overlay)))
+	}
+	return nil
+}
+
+// Overrides settings with environment variables named after their section
+// and key, e.g. LUMACA_SITE_TITLE or LUMACA_SITE_BASE_URL. Variables starting
+// LUMACA_PARAMS_ set params.
+func (c *Config) applyEnv(environ []string) error {
+	vars := make(map[string]string)
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok && strings.HasPrefix(k, envPrefix) {
+			vars[k] = v
+		}
+	}
+
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		sectionField := sections.Type().Field(i)
+		if section.Kind() != reflect.Struct {
+			continue
+		}
+		for j := 0; j < section.NumField(); j++ {
+			name := envPrefix + envName(sectionField) + "_" + envName(section.Type().Field(j))
+			value, ok := vars[name]
+			if !ok {
+				continue
+			}
+			if err := setFromString(section.Field(j), value); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", name, err)
+			}
+		}
+	}
+
+	paramsPrefix := envPrefix + "PARAMS_"
+	for k, v := range vars {
+		if !strings.HasPrefix(k, paramsPrefix) {
+			continue
+		}
+		if c.Params == nil {
+			c.Params = make(map[string]any)
+		}
+		c.Params[strings.ToLower(strings.TrimPrefix(k, paramsPrefix))] = v
+	}
+	return nil
+}
+
+// The upper case form of a field's TOML key
+func envName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+	if name == "" {
+		name = f.Name
+	}
+	return strings.ToUpper(name)
+}
+
+func setFromString(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", s)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// Lists are separated by commas, e.g. 480,800,1200
+		if strings.TrimSpace(s) == "" {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+			return nil
+		}
+		items := strings.Split(s, ",")
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setFromString(list.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		v.Set(list)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Issue is a problem found in a config file
+type Issue struct {
+	Severity Severity
+	Message  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+}
+
+// Returns true if any of the issues are errors
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Describes a decode error, including the line and column of syntax errors
+func decodeError(path string, err error) error {
+	var perr toml.ParseError
+	if errors.As(err, &perr) {
+		return fmt.Errorf("%w %s\n%s", DecodeFileError, path, perr.ErrorWithPosition())
+	}
+	return fmt.Errorf("%w %s: %s", DecodeFileError, path, err)
+}
+
+// Checks the config for unknown keys, missing directories and invalid values
+func (c Config) Validate() []Issue {
+	var issues []Issue
+	warn := func(format string, a ...any) {
+		issues = append(issues, Issue{Warning, fmt.Sprintf(format, a...)})
+	}
+	fail := func(format string, a ...any) {
+		issues = append(issues, Issue{Error, fmt.Sprintf(format, a...)})
+	}
+
+	for _, key := range c.undecoded {
+		warn("unknown key %q is ignored", key)
+	}
+
+	dirs := []struct {
+		key      string
+		path     string
+		required bool
+	}{
+		{"directories.posts", c.Directories.Posts, true},
+		{"directories.pages", c.Directories.Pages, false},
+		// A theme provides these, so the project only needs them without one
+		{"directories.static", c.Directories.Static, c.Site.Theme == ""},
+		{"directories.templates", c.Directories.Templates, c.Site.Theme == ""},
+		{"directories.archetypes", c.Directories.Archetypes, false},
+		{"directories.i18n", c.Directories.I18n, false},
+		{"directories.data", c.Directories.Data, false},
+		{"directories.themes", c.Directories.Themes, false},
+	}
+	for _, d := range dirs {
+		if !d.required && d.path == "" {
+			continue
+		}
+		msg := checkDir(d.path)
+		switch {
+		case msg == "":
+		case d.required:
+			fail("%s: %s", d.key, msg)
+		default:
+			warn("%s: %s", d.key, msg)
+		}
+	}
+	codes := make([]string, 0, len(c.Languages))
+	for code := range c.Languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		lang := c.Languages[code]
+		if lang.ContentDir == "" {
+			continue
+		}
+		if msg := checkDir(lang.ContentDir); msg != "" {
+			warn("languages.%s.content_dir: %s", code, msg)
+		}
+	}
+	if len(c.Languages) > 0 {
+		if _, ok := c.Languages[c.Site.Language]; !ok {
+			warn("site.language %q is not one of the configured languages, the first language by weight is the default", c.Site.Language)
+		}
+	}
+	menus := make([]string, 0, len(c.Menus))
+	for name := range c.Menus {
+		menus = append(menus, name)
+	}
+	sort.Strings(menus)
+	for _, name := range menus {
+		for i, entry := range c.Menus[name] {
+			if entry.Name == "" {
+				fail("menus.%s[%d]: name must be set", name, i)
+			}
+			if entry.URL != "" {
+				if _, err := url.Parse(entry.URL); err != nil {
+					fail("menus.%s[%d]: url %q is not a valid URL", name, i, entry.URL)
+				}
+			}
+		}
+	}
+	if c.Directories.Dist == "" {
+		fail("directories.dist must be set")
+	}
+
+	if c.Files.Extension != "" && !strings.HasPrefix(c.Files.Extension, ".") {
+		fail("files.extension %q must start with a dot, e.g. \".html\"", c.Files.Extension)
+	}
+	if c.Site.BaseURL != "" {
+		u, err := url.Parse(c.Site.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			fail("site.base_url %q must be an absolute URL, e.g. \"https://example.com/\"", c.Site.BaseURL)
+		}
+	}
+	if c.Site.Timezone != "" {
+		if _, err := time.LoadLocation(c.Site.Timezone); err != nil {
+			fail("site.timezone %q is not a known timezone, e.g. \"Europe/London\"", c.Site.Timezone)
+		}
+	}
+	if c.Site.WordsPerMinute < 0 {
+		fail("site.words_per_minute must not be negative")
+	}
+	for _, w := range c.Images.Widths {
+		if w <= 0 {
+			fail("images.widths must be positive, not %d", w)
+		}
+	}
+	if c.Images.Quality < 0 || c.Images.Quality > 100 {
+		fail("images.quality must be between 1 and 100")
+	}
+	return issues
+}
+
+func checkDir(dir string) string {
+	if dir == "" {
+		return "must be set"
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Sprintf("directory %s does not exist", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Sprintf("%s is not a directory", dir)
+	}
+	return ""
+}
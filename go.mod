@@ -9,6 +9,7 @@ require (
 	github.com/gomarkdown/markdown v0.0.0-20231222211730-1d6d20845b47
 	github.com/gosimple/slug v1.14.0
 	github.com/spf13/cobra v1.8.0
+	golang.org/x/image v0.24.0
 )
 
 require (
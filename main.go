@@ -7,7 +7,7 @@ import (
 	"github.com/jmcharter/lumaca/cmd"
 )
 
-//go:embed templates/* content/static/*
+//go:embed templates/* content/static/* archetypes/* i18n/*
 var embeddedFiles embed.FS
 
 func main() {